@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+// resolveApprovers merges the comma-separated list of required approvers with the ones
+// listed in the approvers file (if any), one per line, skipping blank lines and #-comments,
+// and returns the comma-separated list of the distinct approvers.
+func resolveApprovers(requiredApprovers string, approversFile string) (string, error) {
+	if len(approversFile) == 0 {
+		return requiredApprovers, nil
+	}
+	content, err := ioutil.ReadFile(approversFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading required approvers file %s: %v", approversFile, err)
+	}
+	var approvers []string
+	if len(strings.TrimSpace(requiredApprovers)) > 0 {
+		approvers = splitRequiredApprovers(requiredApprovers)
+	}
+	seen := make(map[string]struct{}, len(approvers))
+	for _, approver := range approvers {
+		seen[approver] = struct{}{}
+	}
+	for i, line := range strings.Split(string(content), "\n") {
+		approver := strings.TrimSpace(strings.SplitN(line, "#", 2)[0])
+		if len(approver) == 0 {
+			continue
+		}
+		if strings.ContainsAny(approver, ", \t") {
+			return "", fmt.Errorf("invalid approver \"%s\" on line %d of required approvers file %s: "+
+				"expected one approver per line", approver, i+1, approversFile)
+		}
+		if _, ok := seen[approver]; ok {
+			continue
+		}
+		seen[approver] = struct{}{}
+		approvers = append(approvers, approver)
+	}
+	return strings.Join(approvers, ","), nil
+}
+
+// mergeApprovers adds the specified approvers to the comma-separated list of required
+// approvers and returns the comma-separated list of the distinct approvers.
+func mergeApprovers(requiredApprovers string, approvers []string) string {
+	if len(strings.TrimSpace(requiredApprovers)) > 0 {
+		approvers = append(splitRequiredApprovers(requiredApprovers), approvers...)
+	}
+	if len(approvers) == 0 {
+		return requiredApprovers
+	}
+	return strings.Join(splitRequiredApprovers(strings.Join(approvers, ",")), ",")
+}
+
+// expandTeams replaces the GitHub teams ("<org>/<team>") of the comma-separated list of
+// required approvers with their members and returns the comma-separated list of the
+// distinct approvers. The GitHub API options are only created if there is any team.
+func expandTeams(requiredApprovers string, newOptions func() (*githubOptions, error)) (string, error) {
+	if !strings.Contains(requiredApprovers, "/") {
+		return requiredApprovers, nil
+	}
+	options, err := newOptions()
+	if err != nil {
+		return "", err
+	}
+	var approvers []string
+	for _, approver := range splitRequiredApprovers(requiredApprovers) {
+		if !strings.Contains(approver, "/") {
+			approvers = append(approvers, approver)
+			continue
+		}
+		members, err := resolveTeamMembers(options, strings.TrimPrefix(approver, "@"))
+		if err != nil {
+			return "", err
+		}
+		logger.Info(fmt.Sprintf("Members of GitHub team %s: %s", approver, strings.Join(members, ", ")))
+		approvers = append(approvers, members...)
+	}
+	return mergeApprovers("", approvers), nil
+}
+
+// parseExcusedApprovers parses the comma-separated list of approvers excused from
+// verification. Excuses must be time-limited, so an expiration timestamp (RFC3339) is
+// required; once it has passed, no approver is excused anymore.
+func parseExcusedApprovers(excusedApprovers string, expiresAt string, now time.Time) (map[string]struct{}, error) {
+	excused := make(map[string]struct{})
+	if len(excusedApprovers) == 0 {
+		return excused, nil
+	}
+	if len(expiresAt) == 0 {
+		return nil, errors.New("excused approvers have been specified without an expiration timestamp")
+	}
+	expiration, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing excuse expiration timestamp \"%s\": %v", expiresAt, err)
+	}
+	if !now.Before(expiration) {
+		logger.Warn(fmt.Sprintf(
+			"IGNORING excused approvers %s: the excuse expired at %s", excusedApprovers, expiresAt))
+		return excused, nil
+	}
+	for _, approver := range strings.Split(excusedApprovers, ",") {
+		approver = strings.TrimSpace(approver)
+		if len(approver) > 0 {
+			excused[approver] = struct{}{}
+		}
+	}
+	return excused, nil
+}
+
+// checkApproverOrder makes sure that the approvers in the specified order have notarized
+// the PR one after the other, comparing the timestamps of their notarizations. Approvers
+// without notarization are ignored, as they are reported as missing anyway.
+func checkApproverOrder(order []string, approverDetails map[string]*vcnAPI.LcArtifact) error {
+	var previous string
+	var previousTimestamp time.Time
+	for _, approver := range order {
+		notarization, ok := approverDetails[approver]
+		if !ok || notarization == nil {
+			continue
+		}
+		if len(previous) > 0 && !notarization.Timestamp.After(previousTimestamp) {
+			var timestamps []string
+			for _, a := range order {
+				if n, ok := approverDetails[a]; ok && n != nil {
+					timestamps = append(timestamps, fmt.Sprintf("%s at %s", a, n.Timestamp.Format(time.RFC3339)))
+				}
+			}
+			return fmt.Errorf(
+				"the required approvers must notarize the PR in the order %s, but %s notarized it before %s "+
+					"(notarizations: %s)",
+				strings.Join(order, ", "), approver, previous, strings.Join(timestamps, ", "))
+		}
+		previous, previousTimestamp = approver, notarization.Timestamp
+	}
+	return nil
+}
+
+// configuredApprovers returns the approvers known from the action inputs, before any
+// CNIL call: the current approver, the required ones and the identities of the API keys
+// (of the form <identity>.<secret>). Empty and duplicate entries are skipped silently.
+func configuredApprovers(approver string, requiredApprovers string, cnilAPIKeysStr string) []string {
+	candidates := append([]string{approver}, strings.Split(requiredApprovers, ",")...)
+	for _, ak := range strings.Split(cnilAPIKeysStr, ",") {
+		if i := strings.LastIndex(ak, "."); i > 0 {
+			candidates = append(candidates, strings.TrimSuffix(ak[:i], identitySuffix))
+		}
+	}
+	var approvers []string
+	seen := make(map[string]struct{})
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if _, ok := seen[candidate]; ok || len(candidate) == 0 {
+			continue
+		}
+		seen[candidate] = struct{}{}
+		approvers = append(approvers, candidate)
+	}
+	return approvers
+}
+
+// invalidSignerIDs returns the approvers whose signer ID does not match the specified
+// pattern, along with the signer ID, e.g. "alice (alice@github)".
+func invalidSignerIDs(pattern *regexp.Regexp, approvers []string) []string {
+	var invalid []string
+	for _, approver := range approvers {
+		if signerID := approver + identitySuffix; !pattern.MatchString(signerID) {
+			invalid = append(invalid, fmt.Sprintf("%s (%s)", approver, signerID))
+		}
+	}
+	return invalid
+}
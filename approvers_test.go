@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+func TestCheckApproverOrder(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	notarizedAt := func(minutes int) *vcnAPI.LcArtifact {
+		return &vcnAPI.LcArtifact{Timestamp: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+	order := []string{"dev", "security", "release"}
+	tests := []struct {
+		name    string
+		details map[string]*vcnAPI.LcArtifact
+		wantErr string
+	}{
+		{
+			name:    "in order",
+			details: map[string]*vcnAPI.LcArtifact{"dev": notarizedAt(0), "security": notarizedAt(5), "release": notarizedAt(10)},
+		},
+		{
+			name:    "missing approvers are ignored",
+			details: map[string]*vcnAPI.LcArtifact{"dev": notarizedAt(0), "security": nil, "release": notarizedAt(10)},
+		},
+		{
+			name:    "approvers outside of the order are ignored",
+			details: map[string]*vcnAPI.LcArtifact{"dev": notarizedAt(5), "other": notarizedAt(0)},
+		},
+		{
+			name:    "out of order",
+			details: map[string]*vcnAPI.LcArtifact{"dev": notarizedAt(5), "security": notarizedAt(0)},
+			wantErr: "security notarized it before dev",
+		},
+		{
+			name:    "out of order across a missing approver",
+			details: map[string]*vcnAPI.LcArtifact{"dev": notarizedAt(10), "release": notarizedAt(5)},
+			wantErr: "release notarized it before dev",
+		},
+		{
+			name:    "same timestamp",
+			details: map[string]*vcnAPI.LcArtifact{"dev": notarizedAt(0), "security": notarizedAt(0)},
+			wantErr: "security notarized it before dev",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkApproverOrder(order, tt.details)
+			switch {
+			case len(tt.wantErr) == 0 && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case len(tt.wantErr) > 0 && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("got error %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
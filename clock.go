@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+// defaultMaxClockSkew is the default tolerance of the notarization timestamps check (see
+// the MAX_CLOCK_SKEW env var).
+const defaultMaxClockSkew = 5 * time.Minute
+
+// maxClockSkew is how far in the future of the CNIL server time a notarization timestamp
+// may be, as the signing client clock may be skewed.
+var maxClockSkew = defaultMaxClockSkew
+
+// cnilClockOffset is the offset of the CNIL server clock from the local one.
+var cnilClockOffset time.Duration
+
+type CNILTimeResponse struct {
+	Time time.Time `json:"time"`
+}
+
+// cnilServerTime fetches the current time of the CNIL server from GET <serverURL>/time,
+// falling back to the Date header of GET <serverURL>/version if the former endpoint is not
+// exposed by the CNIL deployment.
+func cnilServerTime(client *http.Client, serverURL string) (time.Time, error) {
+	serverURL = strings.TrimSuffix(serverURL, "/")
+	responsePayload := CNILTimeResponse{}
+	err := sendHTTPRequest(client, nil, http.MethodGet, serverURL+"/time", "", http.StatusOK, nil, &responsePayload)
+	var statusErr *unexpectedStatusError
+	if err == nil && !responsePayload.Time.IsZero() {
+		return responsePayload.Time, nil
+	}
+	if err != nil && !(errors.As(err, &statusErr) &&
+		(statusErr.statusCode == http.StatusNotFound || statusErr.statusCode == http.StatusMethodNotAllowed)) {
+		return time.Time{}, err
+	}
+
+	url := serverURL + "/version"
+	response, err := client.Get(url)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error sending GET %s request: %v", url, err)
+	}
+	response.Body.Close()
+	serverTime, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing the Date header of GET %s response: %v", url, err)
+	}
+	return serverTime, nil
+}
+
+// checkNotarizationTimestamp returns an error if the notarization has no timestamp or if
+// its timestamp is later than the current CNIL server time plus maxClockSkew.
+func checkNotarizationTimestamp(cnilArtifact *vcnAPI.LcArtifact) error {
+	if cnilArtifact.Timestamp.IsZero() {
+		return errors.New("the notarization has no timestamp")
+	}
+	serverNow := time.Now().Add(cnilClockOffset)
+	if skew := cnilArtifact.Timestamp.Sub(serverNow); skew > maxClockSkew {
+		return fmt.Errorf("the notarization timestamp %s is %s ahead of the CNIL server time, "+
+			"which exceeds the maximum clock skew of %s",
+			cnilArtifact.Timestamp.UTC().Format(time.RFC3339), skew.Round(time.Second), maxClockSkew)
+	}
+	return nil
+}
+
+// checkNotarizationAge returns errNotarizationExpired (wrapped with the age) if the
+// notarization is older than maxAge (unless 0), as it is probably stale.
+func checkNotarizationAge(cnilArtifact *vcnAPI.LcArtifact, maxAge time.Duration) error {
+	if maxAge <= 0 || cnilArtifact.Timestamp.IsZero() {
+		return nil
+	}
+	if age := time.Since(cnilArtifact.Timestamp); age > maxAge {
+		return fmt.Errorf("%w: the notarization is %s old, the maximum age is %s",
+			errNotarizationExpired, age.Round(time.Second), maxAge)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// codeownersPaths are the locations of the CODEOWNERS file in the repository, in the order
+// GitHub looks them up.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// codeownersRule maps the files matching a CODEOWNERS pattern to their owners.
+type codeownersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// readCodeowners reads and parses the CODEOWNERS file of the repository at repoDir as of
+// the specified git ref, i.e. the base branch of the PR as GitHub does, so that the PR can
+// not change its own code owners.
+func readCodeowners(repoDir string, ref string) ([]*codeownersRule, error) {
+	for _, path := range codeownersPaths {
+		// ls-tree fails on an unknown ref, but not on a missing file
+		tracked, err := runGit(repoDir, "ls-tree", "--name-only", ref, "--", path)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up CODEOWNERS file %s in %s: %v", path, ref, err)
+		}
+		if len(tracked) == 0 {
+			continue
+		}
+		content, err := runGit(repoDir, "show", ref+":"+path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CODEOWNERS file %s in %s: %v", path, ref, err)
+		}
+		rules, err := parseCodeowners(content)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing CODEOWNERS file %s in %s: %v", path, ref, err)
+		}
+		return rules, nil
+	}
+	return nil, fmt.Errorf("no CODEOWNERS file found in %s: %s", ref, strings.Join(codeownersPaths, ", "))
+}
+
+// parseCodeowners parses the CODEOWNERS rules, keeping only the owners which are GitHub
+// users or teams (<org>/<team>), without their leading @: email addresses are skipped.
+func parseCodeowners(content string) ([]*codeownersRule, error) {
+	var rules []*codeownersRule
+	for i, line := range strings.Split(content, "\n") {
+		if commentIdx := strings.Index(line, "#"); commentIdx >= 0 {
+			line = line[:commentIdx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		pattern, err := codeownersPatternRegexp(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern \"%s\" on line %d: %v", fields[0], i+1, err)
+		}
+		rule := &codeownersRule{pattern: pattern}
+		for _, owner := range fields[1:] {
+			if !strings.HasPrefix(owner, "@") {
+				continue
+			}
+			rule.owners = append(rule.owners, strings.TrimPrefix(owner, "@"))
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// codeownersPatternRegexp converts a CODEOWNERS (gitignore-like) pattern to a regular
+// expression matching the paths of the files it applies to. A pattern without any slash
+// but a trailing one matches at any depth, and a pattern matching a directory applies to
+// all the files under it, except for the "<dir>/*" patterns which only apply to the files
+// directly in the directory.
+func codeownersPatternRegexp(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	filesOnly := strings.HasSuffix(pattern, "/*")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	switch {
+	case dirOnly:
+		expr.WriteString("/.*$")
+	case filesOnly:
+		expr.WriteString("$")
+	default:
+		expr.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(expr.String())
+}
+
+// codeOwners returns the sorted owners of the specified files: as with GitHub, the owners
+// of a file are the ones of the last rule matching it.
+func codeOwners(rules []*codeownersRule, files []string) []string {
+	owners := make(map[string]struct{})
+	for _, file := range files {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if !rules[i].pattern.MatchString(file) {
+				continue
+			}
+			for _, owner := range rules[i].owners {
+				owners[owner] = struct{}{}
+			}
+			break
+		}
+	}
+	sortedOwners := make([]string, 0, len(owners))
+	for owner := range owners {
+		sortedOwners = append(sortedOwners, owner)
+	}
+	sort.Strings(sortedOwners)
+	return sortedOwners
+}
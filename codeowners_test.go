@@ -0,0 +1,199 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodeOwners(t *testing.T) {
+	tests := []struct {
+		name       string
+		codeowners string
+		files      []string
+		want       []string
+	}{
+		{
+			name:       "last matching rule wins",
+			codeowners: "* @default\n*.go @gopher\n/docs/ @writer\n",
+			files:      []string{"docs/main.go"},
+			want:       []string{"writer"},
+		},
+		{
+			name:       "earlier rules apply when the later ones do not match",
+			codeowners: "* @default\n*.go @gopher\n",
+			files:      []string{"README.md", "cmd/main.go"},
+			want:       []string{"default", "gopher"},
+		},
+		{
+			name:       "unanchored directory pattern matches at any depth",
+			codeowners: "build/ @builder\n",
+			files:      []string{"src/build/Makefile", "build/scripts/release.sh"},
+			want:       []string{"builder"},
+		},
+		{
+			name:       "directory pattern does not match files with the same name",
+			codeowners: "build/ @builder\n",
+			files:      []string{"build"},
+			want:       []string{},
+		},
+		{
+			name:       "anchored pattern only matches from the root",
+			codeowners: "/build @builder\n",
+			files:      []string{"src/build/Makefile"},
+			want:       []string{},
+		},
+		{
+			name:       "directory wildcard only matches the files directly in it",
+			codeowners: "docs/* @writer\n",
+			files:      []string{"docs/guide/index.md"},
+			want:       []string{},
+		},
+		{
+			name:       "glob pattern matches files at any depth",
+			codeowners: "*.js @frontend\n",
+			files:      []string{"app.js", "web/src/app.js", "app.jsx"},
+			want:       []string{"frontend"},
+		},
+		{
+			name:       "double asterisk matches nested directories",
+			codeowners: "/apps/**/config.yml @ops\n",
+			files:      []string{"apps/config.yml", "apps/web/prod/config.yml"},
+			want:       []string{"ops"},
+		},
+		{
+			name:       "team owners are kept and emails skipped",
+			codeowners: "* @octo-org/reviewers @octocat dev@example.com # comment\n",
+			files:      []string{"main.go"},
+			want:       []string{"octo-org/reviewers", "octocat"},
+		},
+		{
+			name:       "rule without owners leaves the files unowned",
+			codeowners: "* @default\n/generated/\n",
+			files:      []string{"generated/api.go"},
+			want:       []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := parseCodeowners(test.codeowners)
+			if err != nil {
+				t.Fatalf("parseCodeowners: %v", err)
+			}
+			if got := codeOwners(rules, test.files); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("codeOwners(%v) = %v, want %v", test.files, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadCodeownersFromBaseBranch(t *testing.T) {
+	repoDir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		if _, err := runGit(repoDir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "--quiet")
+	writeTestFiles(t, repoDir, map[string]string{
+		".github/CODEOWNERS": "* @maintainer\n/src/ @security\n",
+		"src/main.go":        "package main\n",
+	})
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "base")
+	git("update-ref", "refs/remotes/origin/main", "HEAD")
+
+	// the PR drops the owners of the files it changes, and moves the file
+	writeTestFiles(t, repoDir, map[string]string{
+		".github/CODEOWNERS": "* @attacker\n",
+		"CODEOWNERS":         "* @attacker\n",
+		"src/main.go":        "package main // changed\n",
+	})
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "pr")
+
+	rules, err := readCodeowners(repoDir, "origin/main")
+	if err != nil {
+		t.Fatalf("readCodeowners: %v", err)
+	}
+	if got, want := codeOwners(rules, []string{"src/main.go"}), []string{"security"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("codeOwners() = %v, want %v", got, want)
+	}
+
+	if _, err := readCodeowners(repoDir, "origin/unknown"); err == nil {
+		t.Error("readCodeowners() of an unknown ref: want error")
+	}
+}
+
+func TestCodeownersPatternRegexp(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		matching []string
+		other    []string
+	}{
+		{
+			pattern:  "*.js",
+			matching: []string{"app.js", "src/lib/app.js"},
+			other:    []string{"app.jsx", "app.js.map"},
+		},
+		{
+			pattern:  "file.txt",
+			matching: []string{"file.txt", "docs/file.txt"},
+			other:    []string{"fileatxt", "file.txt.bak"},
+		},
+		{
+			pattern:  "/docs/",
+			matching: []string{"docs/index.md", "docs/api/v1.md"},
+			other:    []string{"src/docs/index.md", "docs"},
+		},
+		{
+			pattern:  "docs/*",
+			matching: []string{"docs/index.md"},
+			other:    []string{"docs/api/v1.md", "src/docs/index.md"},
+		},
+		{
+			pattern:  "/build/logs/",
+			matching: []string{"build/logs/out.log", "build/logs/2024/out.log"},
+			other:    []string{"src/build/logs/out.log", "build/out.log"},
+		},
+		{
+			pattern:  "**/logs",
+			matching: []string{"logs", "logs/out.log", "deploy/prod/logs/out.log"},
+			other:    []string{"logs.txt", "deploy/mylogs/out.log"},
+		},
+		{
+			pattern:  "apps/**",
+			matching: []string{"apps/web/index.js", "apps/README.md"},
+			other:    []string{"src/apps/web/index.js", "apps"},
+		},
+		{
+			pattern:  "a?c",
+			matching: []string{"abc", "src/abc"},
+			other:    []string{"a/c", "abbc"},
+		},
+		{
+			pattern:  "[regex]+(chars)",
+			matching: []string{"[regex]+(chars)"},
+			other:    []string{"r+(chars)", "regexchars"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			expr, err := codeownersPatternRegexp(tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, path := range tt.matching {
+				if !expr.MatchString(path) {
+					t.Errorf("%s (%s) does not match %s", tt.pattern, expr, path)
+				}
+			}
+			for _, path := range tt.other {
+				if expr.MatchString(path) {
+					t.Errorf("%s (%s) matches %s", tt.pattern, expr, path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+const (
+	compactLineWidth     = 80
+	compactMinHashLength = 12
+)
+
+// compactLine formats a one-line verification result of the compact output, e.g.
+// "[MISSING] bob@github", with the status in the specified color. The details are
+// truncated so that the line fits within compactLineWidth characters (not bytes, so that
+// multi-byte characters are not split).
+func compactLine(status string, statusColor string, signerID string, details ...string) string {
+	line := fmt.Sprintf("[%s] %s", status, signerID)
+	if len(details) > 0 {
+		line += " | " + strings.Join(details, " | ")
+	}
+	if runes := []rune(line); len(runes) > compactLineWidth {
+		line = string(runes[:compactLineWidth-3]) + "..."
+	}
+	return colorize(statusColor, line[:len(status)+2]) + line[len(status)+2:]
+}
+
+// compactVerificationLine formats the compact verification result of a notarization, e.g.
+// "[TRUSTED] alice@github | sha256:abc | 2024-01-15T10:30:00Z", with the status in the
+// color of coloredStatus. The artifact hash is shortened (down to compactMinHashLength
+// characters) for the line to fit.
+func compactVerificationLine(cnilArtifact *vcnAPI.LcArtifact) string {
+	status := strings.ToUpper(cnilArtifact.Status.String())
+	timestamp := cnilArtifact.Timestamp.UTC().Format(time.RFC3339)
+
+	hash := cnilArtifact.Hash
+	fixedLength := utf8.RuneCountInString(
+		fmt.Sprintf("[%s] %s | sha256: | %s", status, cnilArtifact.Signer, timestamp))
+	if maxHashLength := compactLineWidth - fixedLength; len(hash) > maxHashLength {
+		if maxHashLength < compactMinHashLength {
+			maxHashLength = compactMinHashLength
+		}
+		hash = hash[:maxHashLength]
+	}
+	return compactLine(status, statusColor(cnilArtifact.Status), cnilArtifact.Signer, "sha256:"+hash, timestamp)
+}
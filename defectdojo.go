@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defectDojoScanType       = "Generic Findings Import"
+	defectDojoEngagementName = "CodeNotary PR notarization"
+)
+
+type defectDojoOptions struct {
+	url        string
+	apiKey     string
+	productID  string
+	httpClient *http.Client
+}
+
+type DefectDojoProductResponse struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+// DefectDojoFinding is a finding in the DefectDojo Generic Findings Import format.
+type DefectDojoFinding struct {
+	Title            string `json:"title"`
+	Description      string `json:"description"`
+	Severity         string `json:"severity"`
+	Date             string `json:"date"`
+	Active           bool   `json:"active"`
+	Verified         bool   `json:"verified"`
+	IsMitigated      bool   `json:"is_mitigated"`
+	Mitigated        string `json:"mitigated,omitempty"`
+	UniqueIDFromTool string `json:"unique_id_from_tool"`
+	VulnIDFromTool   string `json:"vuln_id_from_tool"`
+	StaticFinding    bool   `json:"static_finding"`
+	ComponentName    string `json:"component_name,omitempty"`
+	ComponentVersion string `json:"component_version,omitempty"`
+}
+
+// pushToDefectDojo imports the verification result as a finding of the DefectDojo
+// product: a closed (mitigated) one if the PR is notarized for all required approvers,
+// an open one with severity High otherwise. The engagement is created if needed.
+func pushToDefectDojo(result *notarizationResult, options *defectDojoOptions) error {
+	baseURL := strings.TrimSuffix(options.url, "/") + "/api/v2"
+
+	product, err := getDefectDojoProduct(options, baseURL)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	finding := DefectDojoFinding{
+		Title: fmt.Sprintf("PR %s notarized for %d of %d required approvers",
+			result.artifactName, len(result.notarizedApprovers), len(result.requiredApprovers)),
+		Description: fmt.Sprintf(
+			"Repository: %s\nArtifact: %s\nHash: %s\nNotarized by: %s\nExcused: %s\nCNIL server version: %s\n"+
+				"vcn library version: %s\n",
+			result.repository, result.artifactName, result.artifactHash,
+			strings.Join(result.notarizedApprovers, ", "),
+			strings.Join(result.excusedApprovers, ", "), result.cnilServerVersion, result.vcnVersion),
+		Severity:         "High",
+		Date:             now.Format("2006-01-02"),
+		Active:           true,
+		Verified:         true,
+		UniqueIDFromTool: result.artifactHash,
+		VulnIDFromTool:   "codenotary-pr-notarization",
+		StaticFinding:    true,
+		ComponentName:    result.repository,
+		ComponentVersion: result.artifactHash,
+	}
+	if result.success {
+		finding.Severity = "Info"
+		finding.Active = false
+		finding.IsMitigated = true
+		finding.Mitigated = now.Format(time.RFC3339)
+	}
+	report, err := json.Marshal(map[string][]DefectDojoFinding{"findings": {finding}})
+	if err != nil {
+		return fmt.Errorf("error JSON-marshaling DefectDojo finding %+v: %v", finding, err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range map[string]string{
+		"scan_type":           defectDojoScanType,
+		"product_name":        product.Name,
+		"engagement_name":     defectDojoEngagementName,
+		"auto_create_context": "true",
+		"close_old_findings":  "false",
+		"active":              fmt.Sprintf("%t", finding.Active),
+		"verified":            "true",
+	} {
+		if err := form.WriteField(name, value); err != nil {
+			return fmt.Errorf("error writing DefectDojo import form field %s: %v", name, err)
+		}
+	}
+	file, err := form.CreateFormFile("file", "codenotary-findings.json")
+	if err != nil {
+		return fmt.Errorf("error writing DefectDojo import form file: %v", err)
+	}
+	if _, err := file.Write(report); err != nil {
+		return fmt.Errorf("error writing DefectDojo import form file: %v", err)
+	}
+	if err := form.Close(); err != nil {
+		return fmt.Errorf("error writing DefectDojo import form: %v", err)
+	}
+
+	return sendDefectDojoRequest(
+		options, http.MethodPost, baseURL+"/import-scan/", form.FormDataContentType(), &body,
+		http.StatusCreated, nil)
+}
+
+func getDefectDojoProduct(options *defectDojoOptions, baseURL string) (*DefectDojoProductResponse, error) {
+	url := fmt.Sprintf("%s/products/%s/", baseURL, options.productID)
+	responsePayload := DefectDojoProductResponse{}
+	if err := sendDefectDojoRequest(
+		options, http.MethodGet, url, "", nil, http.StatusOK, &responsePayload,
+	); err != nil {
+		return nil, err
+	}
+	return &responsePayload, nil
+}
+
+// sendDefectDojoRequest is like sendHTTPRequest, with the DefectDojo token authentication
+// and an explicit request content type.
+func sendDefectDojoRequest(
+	options *defectDojoOptions,
+	method string,
+	url string,
+	contentType string,
+	payload *bytes.Buffer,
+	expectedStatus int,
+	responsePayload interface{},
+) error {
+	var req *http.Request
+	var err error
+	if payload != nil {
+		req, err = http.NewRequest(method, url, payload)
+	} else {
+		req, err = http.NewRequest(method, url, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("error creating HTTP request %s %s: %v", method, url, err)
+	}
+	if len(contentType) > 0 {
+		req.Header.Add("Content-Type", contentType)
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Authorization", "Token "+options.apiKey)
+
+	response, err := options.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request %s %s: %v", method, url, err)
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s: error reading response body: %v", method, url, err)
+	}
+	if response.StatusCode != expectedStatus {
+		return &unexpectedStatusError{
+			method:         method,
+			url:            url,
+			expectedStatus: expectedStatus,
+			status:         response.Status,
+			statusCode:     response.StatusCode,
+			body:           responseBody,
+		}
+	}
+	if responsePayload == nil {
+		return nil
+	}
+	if err := json.Unmarshal(responseBody, responsePayload); err != nil {
+		return fmt.Errorf("error JSON-unmarshaling %s %s response body %s: %v",
+			method, url, responseBody, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	deleteAllKeysCmd    = "--delete-all-keys"
+	apiKeysPageSize     = 100
+	rateLimitMaxRetries = 5
+	rateLimitBaseDelay  = time.Second
+)
+
+// runDeleteAllKeys deletes all API keys of a ledger. Expects args:
+//   - CNIL ledger ID (required)
+//   - -cnil-host (required), -cnil-http-port (optional, default 443) and -yes (optional) flags
+//
+// Without -yes, only a dry-run summary of the keys which would be deleted is printed.
+func runDeleteAllKeys(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: %s <ledger ID> -cnil-host <host> [-cnil-http-port <port>] [-yes]",
+			deleteAllKeysCmd)
+	}
+	ledgerID := args[0]
+
+	flags := flag.NewFlagSet(deleteAllKeysCmd, flag.ContinueOnError)
+	cnilHost := flags.String("cnil-host", "", "CNIL host")
+	cnilRESTPort := flags.String("cnil-http-port", "443", "CNIL REST API port")
+	confirmed := flags.Bool("yes", false, "confirm the deletion of all API keys")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if len(*cnilHost) == 0 {
+		return errors.New("the -cnil-host flag is required")
+	}
+	cnilToken := strings.TrimSpace(os.Getenv("CNIL_PERSONAL_TOKEN"))
+	if len(cnilToken) == 0 {
+		return errors.New("the CNIL_PERSONAL_TOKEN env var is required")
+	}
+
+	options := &cnilOptions{
+		baseURL:  fmt.Sprintf("https://%s:%s/api/v1", *cnilHost, *cnilRESTPort),
+		token:    cnilToken,
+		ledgerID: ledgerID,
+		orgID:    strings.TrimSpace(os.Getenv("CNIL_ORG_ID")),
+	}
+	options.httpClient = newCNILHTTPClient(&tls.Config{MinVersion: tls.VersionTLS12}, options.orgID)
+
+	apiKeys, err := listAPIKeys(options)
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Found %d API key(s) for ledger %s:", len(apiKeys), ledgerID))
+	for _, apiKey := range apiKeys {
+		logger.Info(fmt.Sprintf("   - %s (ID %s)", apiKey.Name, apiKey.ID))
+	}
+	if len(apiKeys) == 0 {
+		return nil
+	}
+	if !*confirmed {
+		logger.Warn("DRY RUN: no API key has been deleted, re-run with -yes to delete them")
+		return nil
+	}
+
+	for _, apiKey := range apiKeys {
+		if err := deleteAPIKey(options, apiKey.ID); err != nil {
+			return fmt.Errorf("error deleting API key %s (ID %s): %v", apiKey.Name, apiKey.ID, err)
+		}
+		logger.Info(fmt.Sprintf("   Deleted API key %s (ID %s)", apiKey.Name, apiKey.ID))
+	}
+	logSuccess(fmt.Sprintf("Successfully deleted %d API key(s) for ledger %s", len(apiKeys), ledgerID))
+	return nil
+}
+
+func listAPIKeys(options *cnilOptions) ([]*APIKeyResponse, error) {
+	var apiKeys []*APIKeyResponse
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/ledgers/%s/api_keys?page=%d&per_page=%d",
+			options.baseURL, options.ledgerID, page, apiKeysPageSize)
+		responsePayload := APIKeysPageResponse{}
+		if err := sendHTTPRequestRateLimited(
+			options.httpClient,
+			http.MethodGet,
+			url,
+			options.token,
+			http.StatusOK,
+			&responsePayload,
+		); err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, responsePayload.Items...)
+		if len(responsePayload.Items) == 0 || uint64(len(apiKeys)) >= responsePayload.Total {
+			return apiKeys, nil
+		}
+	}
+}
+
+func deleteAPIKey(options *cnilOptions, apiKeyID string) error {
+	url := fmt.Sprintf("%s/ledgers/%s/api_keys/%s", options.baseURL, options.ledgerID, apiKeyID)
+	return sendHTTPRequestRateLimited(
+		options.httpClient,
+		http.MethodDelete,
+		url,
+		options.token,
+		http.StatusOK,
+		nil,
+	)
+}
+
+// sendHTTPRequestRateLimited sends a request without payload, retrying it with an
+// exponential backoff as long as the server responds with 429 Too Many Requests.
+func sendHTTPRequestRateLimited(
+	client *http.Client,
+	method string,
+	url string,
+	token string,
+	expectedStatus int,
+	responsePayload interface{},
+) error {
+	delay := rateLimitBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := sendHTTPRequest(client, nil, method, url, token, expectedStatus, nil, responsePayload)
+		var statusErr *unexpectedStatusError
+		if attempt == rateLimitMaxRetries ||
+			!errors.As(err, &statusErr) ||
+			statusErr.statusCode != http.StatusTooManyRequests {
+			return err
+		}
+		logger.Warn(fmt.Sprintf("   Rate limited by the CNIL API, retrying in %s ...", delay))
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
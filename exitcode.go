@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExitCode is the exit code of the action, which gives the category of the failure (if
+// any), so that the next steps of the workflow can e.g. retry on API errors only.
+type ExitCode int
+
+const (
+	// ExitFailure is the exit code of the other failures, e.g. git or file system errors
+	ExitFailure ExitCode = 1
+	// ExitInvalidArgs is the exit code of the invalid args, env vars or input files
+	ExitInvalidArgs ExitCode = 2
+	// ExitAPIError is the exit code of the errors of the CNIL or GitHub APIs (e.g. during
+	// the API keys rotation), which may be transient
+	ExitAPIError ExitCode = 3
+	// ExitNotarizationError is the exit code of the notarization failures
+	ExitNotarizationError ExitCode = 4
+	// ExitNotApproved is the exit code of a PR which is not notarized by (enough of) the
+	// required approvers
+	ExitNotApproved ExitCode = 5
+	// ExitVerificationError is the exit code of the verification failures, e.g. a
+	// notarization which cannot be verified or a failed pre-verification check
+	ExitVerificationError ExitCode = 6
+)
+
+// exitCodeDescriptions documents the exit codes (see exitCodesHelp).
+var exitCodeDescriptions = []struct {
+	code        ExitCode
+	description string
+}{
+	{ExitFailure, "other error (e.g. git or file system error)"},
+	{ExitInvalidArgs, "invalid args, env vars or input files"},
+	{ExitAPIError, "CNIL or GitHub API error (may be transient)"},
+	{ExitNotarizationError, "notarization error"},
+	{ExitNotApproved, "PR not notarized by (enough of) the required approvers"},
+	{ExitVerificationError, "verification error"},
+}
+
+// exitCodesHelp returns the description of the exit codes of the action.
+func exitCodesHelp() string {
+	lines := []string{"Exit codes:", "   0  success"}
+	for _, exitCode := range exitCodeDescriptions {
+		lines = append(lines, fmt.Sprintf("   %d  %s", exitCode.code, exitCode.description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// actionExit is the panic value of exitWith, recovered by runAction.
+type actionExit struct {
+	code ExitCode
+}
+
+// exitWith ends the action with the specified exit code: it unwinds the action up to
+// runAction, so that the deferred functions run on the way (e.g. to encrypt the VCN store).
+func exitWith(code ExitCode) {
+	panic(actionExit{code: code})
+}
+
+// runAction runs the action and returns its exit code: the one passed to exitWith, or 0 if
+// the action returns.
+func runAction(action func()) (code ExitCode) {
+	defer func() {
+		if r := recover(); r != nil {
+			exit, ok := r.(actionExit)
+			if !ok {
+				panic(r)
+			}
+			code = exit.code
+		}
+	}()
+	action()
+	return 0
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runGit runs the git command with the specified args in the repository at repoDir and
+// returns its (trimmed) standard output.
+func runGit(repoDir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", repoDir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error running git %s: %v: %s",
+			strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// checkGitRepoDir returns an error if repoDir is not a directory with a .git entry (a
+// directory, or a file for worktrees and submodules).
+func checkGitRepoDir(repoDir string) error {
+	info, err := os.Stat(repoDir)
+	if err != nil {
+		return fmt.Errorf("error accessing git repository path %s (see ACTION_REPO_PATH): %v", repoDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("git repository path %s is not a directory (see ACTION_REPO_PATH)", repoDir)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, ".git")); err != nil {
+		return fmt.Errorf("%s is not a git repository: no .git entry found (see ACTION_REPO_PATH)", repoDir)
+	}
+	return nil
+}
+
+// gitRepoSizeKiB returns the size (in KiB) of the objects stored in the repository at
+// repoDir (both loose and packed), as reported by "git count-objects -v".
+func gitRepoSizeKiB(repoDir string) (uint64, error) {
+	output, err := runGit(repoDir, "count-objects", "-v")
+	if err != nil {
+		return 0, err
+	}
+	var sizeKiB uint64
+	for _, line := range strings.Split(output, "\n") {
+		pieces := strings.SplitN(line, ":", 2)
+		if len(pieces) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(pieces[0])
+		if key != "size" && key != "size-pack" {
+			continue
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(pieces[1]), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing git count-objects %s value \"%s\": %v", key, pieces[1], err)
+		}
+		sizeKiB += value
+	}
+	return sizeKiB, nil
+}
+
+// gitUncommittedChanges returns the uncommitted changes and untracked files of the
+// repository at repoDir, in "git status --porcelain" format.
+func gitUncommittedChanges(repoDir string) ([]string, error) {
+	output, err := runGit(repoDir, "status", "--porcelain")
+	if err != nil || len(output) == 0 {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// gitHeadCommit returns the hash of the commit checked out in the repository at repoDir.
+func gitHeadCommit(repoDir string) (string, error) {
+	return runGit(repoDir, "rev-parse", "HEAD")
+}
+
+// gitCommitObject returns the hash and the raw (undecorated) content of the commit
+// object of the specified revision of the repository at repoDir.
+func gitCommitObject(repoDir string, rev string) (string, []byte, error) {
+	hash, err := runGit(repoDir, "rev-parse", "--verify", rev+"^{commit}")
+	if err != nil {
+		return "", nil, err
+	}
+	// the content is not trimmed, as runGit does, to keep it byte-for-byte
+	content, err := exec.Command("git", "-C", repoDir, "cat-file", "commit", hash).Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("error running git cat-file commit %s: %v", hash, err)
+	}
+	return hash, content, nil
+}
+
+// gitChangedFiles returns the files of the repository at repoDir added or modified since
+// the merge base of baseRef and the checked out commit.
+func gitChangedFiles(repoDir string, baseRef string) ([]string, error) {
+	output, err := runGit(repoDir, "diff", "--name-only", "--diff-filter=d", baseRef+"...HEAD")
+	if err != nil || len(output) == 0 {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// gitDiffFiles returns the files of the repository at repoDir changed (added, modified or
+// deleted) since the merge base of baseRef and the checked out commit.
+func gitDiffFiles(repoDir string, baseRef string) ([]string, error) {
+	output, err := runGit(repoDir, "diff", "--name-only", baseRef+"...HEAD")
+	if err != nil || len(output) == 0 {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// gitLogSummaries returns the one-line summaries ("<abbreviated hash> <subject>") of the
+// non-merge commits of the repository at repoDir reachable from the checked out commit but
+// not from baseRef, most recent first, up to maxCount of them.
+func gitLogSummaries(repoDir string, baseRef string, maxCount uint64) ([]string, error) {
+	output, err := runGit(repoDir, "log", "--oneline", "--no-merges",
+		fmt.Sprintf("--max-count=%d", maxCount), baseRef+"..HEAD")
+	if err != nil || len(output) == 0 {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// gitHasDiff returns true if any file of the repository at repoDir has been changed
+// (added, modified or deleted) since the merge base of baseRef and the checked out commit.
+func gitHasDiff(repoDir string, baseRef string) (bool, error) {
+	output, err := runGit(repoDir, "diff", "--name-only", baseRef+"...HEAD")
+	return len(output) > 0, err
+}
@@ -0,0 +1,861 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultGitHubAPIURL = "https://api.github.com"
+
+type githubOptions struct {
+	apiURL     string
+	token      string
+	repository string
+	httpClient *http.Client
+}
+
+// gitHubAPIURL returns the URL of the GitHub API from the GitHub Actions environment
+// (GITHUB_API_URL, e.g. of a GitHub Enterprise Server), or the github.com one by default.
+func gitHubAPIURL() string {
+	if apiURL := strings.TrimSuffix(os.Getenv("GITHUB_API_URL"), "/"); len(apiURL) > 0 {
+		return apiURL
+	}
+	return defaultGitHubAPIURL
+}
+
+// newGitHubOptions creates the GitHub API options from the GitHub Actions environment
+// (GITHUB_API_URL, GITHUB_REPOSITORY) and the GITHUB_TOKEN env var, which has to be
+// passed explicitly to the action.
+func newGitHubOptions(httpClient *http.Client) (*githubOptions, error) {
+	options := &githubOptions{
+		apiURL:     gitHubAPIURL(),
+		token:      strings.TrimSpace(os.Getenv("GITHUB_TOKEN")),
+		repository: os.Getenv("GITHUB_REPOSITORY"),
+		httpClient: httpClient,
+	}
+	if len(options.token) == 0 {
+		return nil, errors.New("the GITHUB_TOKEN env var is required")
+	}
+	if len(options.repository) == 0 {
+		return nil, errors.New("the GITHUB_REPOSITORY env var is required")
+	}
+	return options, nil
+}
+
+type GitHubCheckSuite struct {
+	ID         int64  `json:"id"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	App        struct {
+		Name string `json:"name"`
+	} `json:"app"`
+}
+
+type GitHubCheckSuitesResponse struct {
+	TotalCount  int                 `json:"total_count"`
+	CheckSuites []*GitHubCheckSuite `json:"check_suites"`
+}
+
+type GitHubWorkflowRunResponse struct {
+	CheckSuiteID int64 `json:"check_suite_id"`
+}
+
+// unsuccessfulCheckSuites returns a description of each check suite of the specified
+// commit which has not (yet) succeeded, ignoring the check suite of the current workflow
+// run (if any).
+func unsuccessfulCheckSuites(options *githubOptions, sha string) ([]string, error) {
+	var currentCheckSuiteID int64
+	if runID := os.Getenv("GITHUB_RUN_ID"); len(runID) > 0 {
+		url := fmt.Sprintf("%s/repos/%s/actions/runs/%s", options.apiURL, options.repository, runID)
+		run := GitHubWorkflowRunResponse{}
+		if err := sendHTTPRequest(
+			options.httpClient,
+			nil,
+			http.MethodGet,
+			url,
+			options.token,
+			http.StatusOK,
+			nil,
+			&run,
+		); err != nil {
+			return nil, err
+		}
+		currentCheckSuiteID = run.CheckSuiteID
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/commits/%s/check-suites?per_page=100",
+		options.apiURL, options.repository, sha)
+	responsePayload := GitHubCheckSuitesResponse{}
+	if err := sendHTTPRequest(
+		options.httpClient,
+		nil,
+		http.MethodGet,
+		url,
+		options.token,
+		http.StatusOK,
+		nil,
+		&responsePayload,
+	); err != nil {
+		return nil, err
+	}
+
+	var unsuccessful []string
+	for _, checkSuite := range responsePayload.CheckSuites {
+		if checkSuite.ID == currentCheckSuiteID {
+			continue
+		}
+		switch {
+		case checkSuite.Status != "completed":
+			unsuccessful = append(unsuccessful,
+				fmt.Sprintf("%s (%s)", checkSuite.App.Name, checkSuite.Status))
+		case checkSuite.Conclusion != "success" &&
+			checkSuite.Conclusion != "neutral" &&
+			checkSuite.Conclusion != "skipped":
+			unsuccessful = append(unsuccessful,
+				fmt.Sprintf("%s (%s)", checkSuite.App.Name, checkSuite.Conclusion))
+		}
+	}
+	return unsuccessful, nil
+}
+
+type GitHubDeploymentCreateReq struct {
+	Ref              string   `json:"ref"`
+	Environment      string   `json:"environment"`
+	Description      string   `json:"description"`
+	AutoMerge        bool     `json:"auto_merge"`
+	RequiredContexts []string `json:"required_contexts"`
+}
+
+type GitHubDeploymentResponse struct {
+	ID int64 `json:"id"`
+}
+
+type GitHubDeploymentStatusCreateReq struct {
+	State       string `json:"state"`
+	Environment string `json:"environment"`
+	Description string `json:"description"`
+	LogURL      string `json:"log_url,omitempty"`
+}
+
+// createDeploymentStatus creates a GitHub deployment of the specified commit to the
+// environment and sets its status to success or failure, depending on the result.
+func createDeploymentStatus(options *githubOptions, sha string, environment string, result *notarizationResult) error {
+	url := fmt.Sprintf("%s/repos/%s/deployments", options.apiURL, options.repository)
+	payload := GitHubDeploymentCreateReq{
+		Ref:         sha,
+		Environment: environment,
+		Description: "CodeNotary PR notarization",
+		// the deployment is only used to track the verification result: do not merge the
+		// default branch nor wait for other commit statuses
+		RequiredContexts: []string{},
+	}
+	payloadJSON, err := json.Marshal(&payload)
+	if err != nil {
+		return fmt.Errorf("error JSON-marshaling POST %s request with payload %+v: %v", url, payload, err)
+	}
+	deployment := GitHubDeploymentResponse{}
+	if err := sendHTTPRequest(
+		options.httpClient,
+		nil,
+		http.MethodPost,
+		url,
+		options.token,
+		http.StatusCreated,
+		bytes.NewBuffer(payloadJSON),
+		&deployment,
+	); err != nil {
+		return err
+	}
+
+	state := "failure"
+	if result.success {
+		state = "success"
+	}
+	statusPayload := GitHubDeploymentStatusCreateReq{
+		State:       state,
+		Environment: environment,
+		Description: fmt.Sprintf("PR notarized for %d of %d required approvers",
+			len(result.notarizedApprovers), len(result.requiredApprovers)),
+	}
+	statusPayload.LogURL = gitHubRunURL(options)
+	url = fmt.Sprintf("%s/repos/%s/deployments/%d/statuses", options.apiURL, options.repository, deployment.ID)
+	statusPayloadJSON, err := json.Marshal(&statusPayload)
+	if err != nil {
+		return fmt.Errorf("error JSON-marshaling POST %s request with payload %+v: %v", url, statusPayload, err)
+	}
+	return sendHTTPRequest(
+		options.httpClient,
+		nil,
+		http.MethodPost,
+		url,
+		options.token,
+		http.StatusCreated,
+		bytes.NewBuffer(statusPayloadJSON),
+		nil,
+	)
+}
+
+// gitHubRunURL returns the URL of the GitHub Actions workflow run (if known).
+func gitHubRunURL(options *githubOptions) string {
+	runID := os.Getenv("GITHUB_RUN_ID")
+	if len(runID) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s",
+		strings.TrimSuffix(os.Getenv("GITHUB_SERVER_URL"), "/"), options.repository, runID)
+}
+
+// githubPermissionProbes maps GitHub token permissions to a read-only API endpoint of the
+// repository (with {sha} replaced by the PR commit) which requires them.
+var githubPermissionProbes = map[string]string{
+	"actions":     "actions/runs?per_page=1",
+	"checks":      "commits/{sha}/check-suites?per_page=1",
+	"deployments": "deployments?per_page=1",
+	// only read access can be checked, the review requests require write access
+	"pull-requests": "pulls?per_page=1",
+	"statuses":      "commits/{sha}/statuses?per_page=1",
+}
+
+type GitHubActionsPermissionsResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// gitHubAcceptedPermissionsHeader is the header of the GitHub API responses listing the
+// permissions accepted by the endpoint, e.g. "administration=read".
+const gitHubAcceptedPermissionsHeader = "X-Accepted-GitHub-Permissions"
+
+// checkGitHubPermissions makes sure that GitHub Actions are enabled for the repository and
+// that the GitHub token has been granted the specified permissions, by calling an API
+// endpoint requiring each of them. Only read access can be checked this way. Reading the
+// Actions permissions of the repository requires the administration permission, which
+// the GITHUB_TOKEN of the workflow run can not be granted (unlike a GitHub App).
+func checkGitHubPermissions(options *githubOptions, sha string, permissions []string) error {
+	url := fmt.Sprintf("%s/repos/%s/actions/permissions", options.apiURL, options.repository)
+	actionsPermissions := GitHubActionsPermissionsResponse{}
+	err := sendHTTPRequest(options.httpClient, nil, http.MethodGet, url, options.token, http.StatusOK, nil, &actionsPermissions)
+	var statusErr *unexpectedStatusError
+	switch {
+	case errors.As(err, &statusErr) &&
+		(statusErr.statusCode == http.StatusForbidden || statusErr.statusCode == http.StatusNotFound):
+		return fmt.Errorf("the GitHub token can not check that GitHub Actions are enabled for repository %s "+
+			"(accepted permissions: %s)", options.repository, acceptedGitHubPermissions(statusErr, "administration=read"))
+	case err != nil:
+		return err
+	case !actionsPermissions.Enabled:
+		return fmt.Errorf("GitHub Actions are disabled for repository %s", options.repository)
+	}
+
+	var missing []string
+	for _, permission := range permissions {
+		probe, ok := githubPermissionProbes[permission]
+		if !ok {
+			return fmt.Errorf("unsupported GitHub permission %s", permission)
+		}
+		url := fmt.Sprintf("%s/repos/%s/%s",
+			options.apiURL, options.repository, strings.Replace(probe, "{sha}", sha, 1))
+		err := sendHTTPRequest(options.httpClient, nil, http.MethodGet, url, options.token, http.StatusOK, nil, nil)
+		switch {
+		case errors.As(err, &statusErr) &&
+			(statusErr.statusCode == http.StatusForbidden || statusErr.statusCode == http.StatusNotFound):
+			missing = append(missing, fmt.Sprintf("%s (accepted permissions: %s)",
+				permission, acceptedGitHubPermissions(statusErr, permission+"=read")))
+		case err != nil:
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the GitHub token has not been granted the following permission(s): %s "+
+			"(see the permissions key of the workflow)", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// acceptedGitHubPermissions returns the permissions accepted by the endpoint of the GitHub
+// API error, as listed by its X-Accepted-GitHub-Permissions header, or the specified
+// default ones if not listed.
+func acceptedGitHubPermissions(statusErr *unexpectedStatusError, defaultPermissions string) string {
+	if accepted := strings.TrimSpace(statusErr.header.Get(gitHubAcceptedPermissionsHeader)); len(accepted) > 0 {
+		return accepted
+	}
+	return defaultPermissions
+}
+
+// gitHubPullRequestNumber returns the number of the PR which triggered the current
+// workflow run, from GITHUB_REF (refs/pull/<number>/merge) or from the event payload.
+func gitHubPullRequestNumber() (int, error) {
+	if ref := os.Getenv("GITHUB_REF"); strings.HasPrefix(ref, "refs/pull/") {
+		if number, err := strconv.Atoi(strings.Split(strings.TrimPrefix(ref, "refs/pull/"), "/")[0]); err == nil {
+			return number, nil
+		}
+	}
+	eventPath := os.Getenv("GITHUB_EVENT_PATH")
+	if len(eventPath) == 0 {
+		return 0, errors.New("the workflow run has not been triggered by a pull request")
+	}
+	eventJSON, err := ioutil.ReadFile(eventPath)
+	if err != nil {
+		return 0, fmt.Errorf("error reading GitHub event file %s: %v", eventPath, err)
+	}
+	event := struct {
+		PullRequest *struct {
+			Number int `json:"number"`
+		} `json:"pull_request"`
+	}{}
+	if err := json.Unmarshal(eventJSON, &event); err != nil {
+		return 0, fmt.Errorf("error JSON-unmarshaling GitHub event file %s: %v", eventPath, err)
+	}
+	if event.PullRequest == nil {
+		return 0, errors.New("the workflow run has not been triggered by a pull request")
+	}
+	return event.PullRequest.Number, nil
+}
+
+type GitHubReviewersRequestReq struct {
+	Reviewers []string `json:"reviewers"`
+}
+
+// requestReviews requests the review of the PR with the specified number from the
+// specified GitHub users (requires the pull-requests write permission).
+func requestReviews(options *githubOptions, prNumber int, reviewers []string) error {
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d/requested_reviewers", options.apiURL, options.repository, prNumber)
+	payload := GitHubReviewersRequestReq{Reviewers: reviewers}
+	payloadJSON, err := json.Marshal(&payload)
+	if err != nil {
+		return fmt.Errorf("error JSON-marshaling POST %s request with payload %+v: %v", url, payload, err)
+	}
+	return sendHTTPRequest(
+		options.httpClient,
+		nil,
+		http.MethodPost,
+		url,
+		options.token,
+		http.StatusCreated,
+		bytes.NewBuffer(payloadJSON),
+		nil,
+	)
+}
+
+type GitHubUser struct {
+	Login string `json:"login"`
+}
+
+type GitHubTeam struct {
+	Slug string `json:"slug"`
+}
+
+type GitHubRequestedReviewersResponse struct {
+	Users []*GitHubUser `json:"users"`
+	Teams []*GitHubTeam `json:"teams"`
+}
+
+type GitHubReview struct {
+	User  *GitHubUser `json:"user"`
+	State string      `json:"state"`
+}
+
+// fetchPRReviewers returns the sorted GitHub users and teams (<org>/<team>, see
+// expandTeams) whose review of the PR with the specified number has been requested, along
+// with the users who have submitted a review which has not been dismissed. As GitHub
+// removes the reviewers who submit a review from the requested ones, whatever the review
+// (e.g. changes requested), the reviewers are not dropped by objecting.
+func fetchPRReviewers(options *githubOptions, prNumber int) ([]string, error) {
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d/requested_reviewers", options.apiURL, options.repository, prNumber)
+	requested := GitHubRequestedReviewersResponse{}
+	if err := sendHTTPRequest(options.httpClient, nil, http.MethodGet, url, options.token, http.StatusOK, nil, &requested); err != nil {
+		return nil, err
+	}
+	reviewers := make(map[string]struct{})
+	for _, user := range requested.Users {
+		reviewers[user.Login] = struct{}{}
+	}
+	// the requested teams belong to the organization of the repository
+	org := strings.SplitN(options.repository, "/", 2)[0]
+	for _, team := range requested.Teams {
+		reviewers[org+"/"+team.Slug] = struct{}{}
+	}
+
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/pulls/%d/reviews?per_page=100&page=%d",
+			options.apiURL, options.repository, prNumber, page)
+		var reviews []*GitHubReview
+		if err := sendHTTPRequest(options.httpClient, nil, http.MethodGet, url, options.token, http.StatusOK, nil, &reviews); err != nil {
+			return nil, err
+		}
+		for _, review := range reviews {
+			if review.User != nil && review.State != "DISMISSED" && review.State != "PENDING" {
+				reviewers[review.User.Login] = struct{}{}
+			}
+		}
+		if len(reviews) < 100 {
+			break
+		}
+	}
+
+	sortedReviewers := make([]string, 0, len(reviewers))
+	for reviewer := range reviewers {
+		sortedReviewers = append(sortedReviewers, reviewer)
+	}
+	sort.Strings(sortedReviewers)
+	return sortedReviewers, nil
+}
+
+// nonOrgMembers returns the specified GitHub users which are not members of the
+// organization. Private memberships are only visible with the token of an organization
+// member, otherwise GitHub redirects to the public membership check.
+func nonOrgMembers(options *githubOptions, org string, usernames []string) ([]string, error) {
+	var nonMembers []string
+	for _, username := range usernames {
+		url := fmt.Sprintf("%s/orgs/%s/members/%s", options.apiURL, org, username)
+		err := sendHTTPRequest(options.httpClient, nil, http.MethodGet, url, options.token, http.StatusNoContent, nil, nil)
+		var statusErr *unexpectedStatusError
+		switch {
+		case errors.As(err, &statusErr) && statusErr.statusCode == http.StatusNotFound:
+			nonMembers = append(nonMembers, username)
+		case err != nil:
+			return nil, err
+		}
+	}
+	return nonMembers, nil
+}
+
+// teamMembersCache holds the members of the teams resolved by resolveTeamMembers, so that
+// each team is only fetched once per run.
+var teamMembersCache = make(map[string][]string)
+
+// resolveTeamMembers returns the members of the GitHub team ("<org>/<team>"), which
+// requires a token with the read:org scope.
+func resolveTeamMembers(options *githubOptions, orgTeam string) ([]string, error) {
+	if members, ok := teamMembersCache[orgTeam]; ok {
+		return members, nil
+	}
+	pieces := strings.Split(orgTeam, "/")
+	if len(pieces) != 2 || len(pieces[0]) == 0 || len(pieces[1]) == 0 {
+		return nil, fmt.Errorf("invalid GitHub team \"%s\": expected <org>/<team>", orgTeam)
+	}
+	var members []string
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/orgs/%s/teams/%s/members?per_page=100&page=%d",
+			options.apiURL, url.PathEscape(pieces[0]), url.PathEscape(pieces[1]), page)
+		var users []*GitHubUser
+		err := sendHTTPRequest(options.httpClient, nil, http.MethodGet, url, options.token, http.StatusOK, nil, &users)
+		var statusErr *unexpectedStatusError
+		if errors.As(err, &statusErr) &&
+			(statusErr.statusCode == http.StatusForbidden || statusErr.statusCode == http.StatusNotFound) {
+			return nil, fmt.Errorf("error getting the members of GitHub team %s: the team does not exist "+
+				"or GITHUB_TOKEN lacks the read:org scope: %v", orgTeam, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting the members of GitHub team %s: %v", orgTeam, err)
+		}
+		for _, user := range users {
+			members = append(members, user.Login)
+		}
+		if len(users) < 100 {
+			break
+		}
+	}
+	teamMembersCache[orgTeam] = members
+	return members, nil
+}
+
+// gitHubEventReview returns the ID of the PR review which triggered the current workflow
+// run (pull_request_review event), along with the login of its author.
+func gitHubEventReview() (int64, string, error) {
+	eventPath := os.Getenv("GITHUB_EVENT_PATH")
+	if len(eventPath) == 0 {
+		return 0, "", errors.New("the workflow run has not been triggered by a pull request review")
+	}
+	eventJSON, err := ioutil.ReadFile(eventPath)
+	if err != nil {
+		return 0, "", fmt.Errorf("error reading GitHub event file %s: %v", eventPath, err)
+	}
+	event := struct {
+		Review *struct {
+			ID   int64 `json:"id"`
+			User struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		} `json:"review"`
+	}{}
+	if err := json.Unmarshal(eventJSON, &event); err != nil {
+		return 0, "", fmt.Errorf("error JSON-unmarshaling GitHub event file %s: %v", eventPath, err)
+	}
+	if event.Review == nil {
+		return 0, "", errors.New("the workflow run has not been triggered by a pull request review")
+	}
+	return event.Review.ID, event.Review.User.Login, nil
+}
+
+type GitHubReviewComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+// reviewCommentsHash returns the hex-encoded SHA256 hash of the comments of the specified
+// PR review, in their creation order.
+func reviewCommentsHash(options *githubOptions, prNumber int, reviewID int64) (string, error) {
+	hash := sha256.New()
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/pulls/%d/reviews/%d/comments?per_page=100&page=%d",
+			options.apiURL, options.repository, prNumber, reviewID, page)
+		var comments []*GitHubReviewComment
+		if err := sendHTTPRequest(
+			options.httpClient,
+			nil,
+			http.MethodGet,
+			url,
+			options.token,
+			http.StatusOK,
+			nil,
+			&comments,
+		); err != nil {
+			return "", err
+		}
+		for _, comment := range comments {
+			hash.Write([]byte(comment.Body))
+			hash.Write([]byte("\n"))
+		}
+		if len(comments) < 100 {
+			return hex.EncodeToString(hash.Sum(nil)), nil
+		}
+	}
+}
+
+// approverReviewCommentsHash returns the hash of the comments of the PR review of the
+// approver which triggered the current workflow run.
+func approverReviewCommentsHash(httpClient *http.Client, approver string) (string, error) {
+	options, err := newGitHubOptions(httpClient)
+	if err != nil {
+		return "", err
+	}
+	prNumber, err := gitHubPullRequestNumber()
+	if err != nil {
+		return "", err
+	}
+	reviewID, reviewer, err := gitHubEventReview()
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(reviewer, approver) {
+		return "", fmt.Errorf("the review which triggered the workflow run is by %s", reviewer)
+	}
+	return reviewCommentsHash(options, prNumber, reviewID)
+}
+
+// resultCommentMarker identifies the PR comments posted by postResultComment.
+const resultCommentMarker = "<!-- codenotary-pr-notarization -->"
+
+type GitHubIssueComment struct {
+	ID   int64       `json:"id"`
+	Body string      `json:"body"`
+	User *GitHubUser `json:"user"`
+}
+
+type GitHubIssueCommentCreateReq struct {
+	Body string `json:"body"`
+}
+
+// gitHubActionsBotLogin is the author of the comments posted with the GITHUB_TOKEN of the
+// workflow run.
+const gitHubActionsBotLogin = "github-actions[bot]"
+
+// gitHubAppBotLogin is the author of the comments posted with the token of the GitHub App
+// installation (if authenticated as a GitHub App, see generateGitHubAppToken).
+var gitHubAppBotLogin string
+
+// gitHubTokenLogin returns the login of the user authenticated by the GitHub token. The
+// installation tokens (the GITHUB_TOKEN of the workflow run and the GitHub App ones) can not
+// get the authenticated user, so they are the corresponding bot.
+func gitHubTokenLogin(options *githubOptions) (string, error) {
+	url := fmt.Sprintf("%s/user", options.apiURL)
+	var user GitHubUser
+	err := sendHTTPRequest(options.httpClient, nil, http.MethodGet, url, options.token, http.StatusOK, nil, &user)
+	var statusErr *unexpectedStatusError
+	switch {
+	case err == nil:
+		return user.Login, nil
+	case errors.As(err, &statusErr) && statusErr.statusCode == http.StatusForbidden:
+		if len(gitHubAppBotLogin) > 0 {
+			return gitHubAppBotLogin, nil
+		}
+		return gitHubActionsBotLogin, nil
+	default:
+		return "", err
+	}
+}
+
+// postResultComment posts a comment with the result on the PR with the specified number,
+// or updates the one previously posted by the action (with the same GitHub token user) so
+// that they do not accumulate. Any other comment previously posted by the action is deleted.
+func postResultComment(options *githubOptions, prNumber int, result *notarizationResult) error {
+	login, err := gitHubTokenLogin(options)
+	if err != nil {
+		return err
+	}
+	// all the pages are listed before deleting any comment, which would shift the pages
+	var previousCommentIDs []int64
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/issues/%d/comments?per_page=100&page=%d",
+			options.apiURL, options.repository, prNumber, page)
+		var comments []*GitHubIssueComment
+		if err := sendHTTPRequest(options.httpClient, nil, http.MethodGet, url, options.token, http.StatusOK, nil, &comments); err != nil {
+			return err
+		}
+		for _, comment := range comments {
+			if comment.User != nil && strings.EqualFold(comment.User.Login, login) &&
+				strings.Contains(comment.Body, resultCommentMarker) {
+				previousCommentIDs = append(previousCommentIDs, comment.ID)
+			}
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+
+	payload := GitHubIssueCommentCreateReq{
+		Body: fmt.Sprintf("%s\n%s\nArtifact hash: `%s`\n", resultCommentMarker, resultMarkdown(result), result.artifactHash),
+	}
+	method, expectedStatus := http.MethodPost, http.StatusCreated
+	url := fmt.Sprintf("%s/repos/%s/issues/%d/comments", options.apiURL, options.repository, prNumber)
+	if len(previousCommentIDs) > 0 {
+		method, expectedStatus = http.MethodPatch, http.StatusOK
+		url = fmt.Sprintf("%s/repos/%s/issues/comments/%d", options.apiURL, options.repository, previousCommentIDs[0])
+	}
+	payloadJSON, err := json.Marshal(&payload)
+	if err != nil {
+		return fmt.Errorf("error JSON-marshaling %s %s request with payload %+v: %v", method, url, payload, err)
+	}
+	if err := sendHTTPRequest(
+		options.httpClient,
+		nil,
+		method,
+		url,
+		options.token,
+		expectedStatus,
+		bytes.NewBuffer(payloadJSON),
+		nil,
+	); err != nil {
+		return err
+	}
+
+	// the comment updated is the first one
+	for i := 1; i < len(previousCommentIDs); i++ {
+		url := fmt.Sprintf("%s/repos/%s/issues/comments/%d", options.apiURL, options.repository, previousCommentIDs[i])
+		if err := sendHTTPRequest(options.httpClient, nil, http.MethodDelete, url, options.token, http.StatusNoContent, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitStatusContext identifies the commit statuses set by setCommitStatus.
+const commitStatusContext = "codenotary/notarize-verify"
+
+// maxCommitStatusDescriptionLen is the maximum length of a commit status description.
+const maxCommitStatusDescriptionLen = 140
+
+type GitHubCommitStatusCreateReq struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description"`
+	Context     string `json:"context"`
+}
+
+// setCommitStatus sets the state (error, failure, pending or success) of the commit status
+// of the action on the specified commit.
+func setCommitStatus(options *githubOptions, sha string, state string, description string) error {
+	if len(description) > maxCommitStatusDescriptionLen {
+		description = description[:maxCommitStatusDescriptionLen-3] + "..."
+	}
+	url := fmt.Sprintf("%s/repos/%s/statuses/%s", options.apiURL, options.repository, sha)
+	payload := GitHubCommitStatusCreateReq{
+		State:       state,
+		TargetURL:   gitHubRunURL(options),
+		Description: description,
+		Context:     commitStatusContext,
+	}
+	payloadJSON, err := json.Marshal(&payload)
+	if err != nil {
+		return fmt.Errorf("error JSON-marshaling POST %s request with payload %+v: %v", url, payload, err)
+	}
+	return sendHTTPRequest(
+		options.httpClient,
+		nil,
+		http.MethodPost,
+		url,
+		options.token,
+		http.StatusCreated,
+		bytes.NewBuffer(payloadJSON),
+		nil,
+	)
+}
+
+// commitStatusOfResult returns the commit status state and description matching the exit
+// code and the JSON result of the action, which has no artifact hash if the action ended
+// before verifying the PR.
+func commitStatusOfResult(exitCode int, resultJSON *NotarizationResultJSON) (string, string) {
+	verified := len(resultJSON.ArtifactHash) > 0
+	switch {
+	case !verified && exitCode == 0:
+		return "success", "PR verification skipped"
+	case !verified || (exitCode != 0 && resultJSON.Success):
+		if len(resultJSON.Error) > 0 {
+			return "error", resultJSON.Error
+		}
+		return "error", "The action failed, see the workflow run logs"
+	}
+	state := "failure"
+	if resultJSON.Success {
+		state = "success"
+	}
+	return state, fmt.Sprintf("PR notarized for %d of %d required approvers",
+		len(resultJSON.NotarizedApprovers), len(resultJSON.RequiredApprovers))
+}
+
+// checkRunName is the name of the check run created by createOrUpdateCheckRun.
+const checkRunName = "CodeNotary PR notarization"
+
+type GitHubCheckRunAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title,omitempty"`
+	Message         string `json:"message"`
+}
+
+type GitHubCheckRunOutput struct {
+	Title       string                      `json:"title"`
+	Summary     string                      `json:"summary"`
+	Annotations []*GitHubCheckRunAnnotation `json:"annotations,omitempty"`
+}
+
+type GitHubCheckRunReq struct {
+	Name       string                `json:"name,omitempty"`
+	HeadSHA    string                `json:"head_sha,omitempty"`
+	Status     string                `json:"status"`
+	Conclusion string                `json:"conclusion"`
+	DetailsURL string                `json:"details_url,omitempty"`
+	Output     *GitHubCheckRunOutput `json:"output"`
+}
+
+type GitHubCheckRunsResponse struct {
+	CheckRuns []struct {
+		ID int64 `json:"id"`
+	} `json:"check_runs"`
+}
+
+// createOrUpdateCheckRun creates a completed check run with the result on the specified
+// commit, with an annotation for each required approver who has not notarized the PR. The
+// check run of a previous run of the action on the commit (if any) is updated instead.
+func createOrUpdateCheckRun(options *githubOptions, sha string, result *notarizationResult) error {
+	// the annotations must refer to a file of the repository: the workflow file, if known
+	annotationPath := ".github"
+	if workflowRef := os.Getenv("GITHUB_WORKFLOW_REF"); len(workflowRef) > 0 {
+		workflowPath := strings.TrimPrefix(strings.SplitN(workflowRef, "@", 2)[0], options.repository+"/")
+		if len(workflowPath) > 0 {
+			annotationPath = workflowPath
+		}
+	}
+	output := &GitHubCheckRunOutput{Summary: resultMarkdown(result)}
+	if result.success {
+		output.Title = fmt.Sprintf("PR notarized for %d of %d required approvers",
+			len(result.notarizedApprovers), len(result.requiredApprovers))
+	} else {
+		output.Title = fmt.Sprintf("PR NOT notarized: %d of %d required approvers",
+			len(result.notarizedApprovers), len(result.requiredApprovers))
+	}
+	for _, missingApprover := range result.missingApprovers {
+		output.Annotations = append(output.Annotations, &GitHubCheckRunAnnotation{
+			Path:            annotationPath,
+			StartLine:       1,
+			EndLine:         1,
+			AnnotationLevel: "warning",
+			Title:           "Missing notarization",
+			Message: fmt.Sprintf("PR artifact %s is not notarized for required approver %s",
+				result.artifactHash, missingApprover),
+		})
+	}
+	payload := GitHubCheckRunReq{
+		Status:     "completed",
+		Conclusion: "failure",
+		DetailsURL: gitHubRunURL(options),
+		Output:     output,
+	}
+	if result.success {
+		payload.Conclusion = "success"
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/commits/%s/check-runs?check_name=%s&filter=latest",
+		options.apiURL, options.repository, sha, url.QueryEscape(checkRunName))
+	checkRuns := GitHubCheckRunsResponse{}
+	if err := sendHTTPRequest(options.httpClient, nil, http.MethodGet, url, options.token, http.StatusOK, nil, &checkRuns); err != nil {
+		return err
+	}
+	method, expectedStatus := http.MethodPost, http.StatusCreated
+	url = fmt.Sprintf("%s/repos/%s/check-runs", options.apiURL, options.repository)
+	if len(checkRuns.CheckRuns) > 0 {
+		method, expectedStatus = http.MethodPatch, http.StatusOK
+		url = fmt.Sprintf("%s/%d", url, checkRuns.CheckRuns[0].ID)
+	} else {
+		payload.Name, payload.HeadSHA = checkRunName, sha
+	}
+	payloadJSON, err := json.Marshal(&payload)
+	if err != nil {
+		return fmt.Errorf("error JSON-marshaling %s %s request with payload %+v: %v", method, url, payload, err)
+	}
+	return sendHTTPRequest(
+		options.httpClient,
+		nil,
+		method,
+		url,
+		options.token,
+		expectedStatus,
+		bytes.NewBuffer(payloadJSON),
+		nil,
+	)
+}
+
+type GitHubAttestationCreateReq struct {
+	Bundle json.RawMessage `json:"bundle"`
+}
+
+type GitHubAttestationResponse struct {
+	ID int64 `json:"id"`
+}
+
+// createGitHubAttestation submits the Sigstore bundle of the PR artifact to the GitHub
+// Attestations API of the repository, and returns the ID of the attestation, which can then
+// be verified with "gh attestation verify".
+func createGitHubAttestation(options *githubOptions, bundle []byte) (int64, error) {
+	url := fmt.Sprintf("%s/repos/%s/attestations", options.apiURL, options.repository)
+	payloadJSON, err := json.Marshal(&GitHubAttestationCreateReq{Bundle: bundle})
+	if err != nil {
+		return 0, fmt.Errorf("error JSON-marshaling POST %s request: %v", url, err)
+	}
+	response := GitHubAttestationResponse{}
+	if err := sendHTTPRequest(
+		options.httpClient,
+		nil,
+		http.MethodPost,
+		url,
+		options.token,
+		http.StatusCreated,
+		bytes.NewBuffer(payloadJSON),
+		&response,
+	); err != nil {
+		return 0, err
+	}
+	return response.ID, nil
+}
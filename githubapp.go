@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// gitHubAppJWTLifetime is the lifetime of the JWT authenticating as the GitHub App, which
+// GitHub limits to 10 minutes.
+const gitHubAppJWTLifetime = 9 * time.Minute
+
+type GitHubInstallationTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type GitHubApp struct {
+	Slug string `json:"slug"`
+}
+
+// gitHubAppJWT creates the RS256 JWT authenticating as the GitHub App, issued a minute in
+// the past to allow for clock drift, as recommended by GitHub.
+func gitHubAppJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(gitHubAppJWTLifetime)),
+		Issuer:    strconv.FormatInt(appID, 10),
+	})
+	signedToken, err := token.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("error signing JWT: %v", err)
+	}
+	return signedToken, nil
+}
+
+// generateGitHubAppToken authenticates as the GitHub App with the specified ID and private
+// key, and exchanges the JWT for a token of the specified installation of the app, which
+// expires after an hour. The login of the app bot is kept in gitHubAppBotLogin.
+func generateGitHubAppToken(appID int64, privateKey []byte, installationID int64) (string, error) {
+	// PKCS#1 (as downloaded from GitHub) or PKCS#8 format
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("error parsing GitHub App private key: %v", err)
+	}
+	appJWT, err := gitHubAppJWT(appID, key, time.Now())
+	if err != nil {
+		return "", err
+	}
+	apiURL := gitHubAPIURL()
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", apiURL, installationID)
+	response := GitHubInstallationTokenResponse{}
+	if err := sendHTTPRequest(
+		newHTTPClient(nil), nil, http.MethodPost, url, appJWT, http.StatusCreated, nil, &response); err != nil {
+		return "", fmt.Errorf("error creating a token for GitHub App installation %d: %v", installationID, err)
+	}
+	if len(response.Token) == 0 {
+		return "", fmt.Errorf("no token returned for GitHub App installation %d", installationID)
+	}
+
+	// the bot of the app is the author of the comments posted with the installation token
+	app := GitHubApp{}
+	if err := sendHTTPRequest(
+		newHTTPClient(nil), nil, http.MethodGet, apiURL+"/app", appJWT, http.StatusOK, nil, &app); err != nil {
+		return "", fmt.Errorf("error getting GitHub App %d: %v", appID, err)
+	}
+	gitHubAppBotLogin = app.Slug + "[bot]"
+	return response.Token, nil
+}
+
+// gitHubAppTokenFromEnv generates a GitHub App installation token (see
+// generateGitHubAppToken) from the GITHUB_APP_ID, GITHUB_APP_PRIVATE_KEY (PEM content) and
+// GITHUB_APP_INSTALLATION_ID env vars.
+func gitHubAppTokenFromEnv() (string, error) {
+	appID, err := strconv.ParseInt(strings.TrimSpace(os.Getenv("GITHUB_APP_ID")), 10, 64)
+	if err != nil || appID <= 0 {
+		return "", fmt.Errorf("invalid GITHUB_APP_ID \"%s\": expected a positive integer", os.Getenv("GITHUB_APP_ID"))
+	}
+	installationID, err := strconv.ParseInt(strings.TrimSpace(os.Getenv("GITHUB_APP_INSTALLATION_ID")), 10, 64)
+	if err != nil || installationID <= 0 {
+		return "", fmt.Errorf("invalid GITHUB_APP_INSTALLATION_ID \"%s\": expected a positive integer",
+			os.Getenv("GITHUB_APP_INSTALLATION_ID"))
+	}
+	privateKey := strings.TrimSpace(os.Getenv("GITHUB_APP_PRIVATE_KEY"))
+	if len(privateKey) == 0 {
+		return "", errors.New("the GITHUB_APP_PRIVATE_KEY env var is required")
+	}
+	return generateGitHubAppToken(appID, []byte(privateKey), installationID)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+var (
+	// sensitiveHeaders are redacted from the network trace
+	sensitiveHeaders = map[string]bool{
+		"Authorization": true,
+		"Cookie":        true,
+		"Set-Cookie":    true,
+		"X-Api-Key":     true,
+	}
+	// sensitiveJSONFields matches the JSON fields redacted from the network trace bodies
+	sensitiveJSONFields = regexp.MustCompile(`"(key|api_key|token|password)"\s*:\s*"[^"]*"`)
+)
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	Cookies     []harNameValue `json:"cookies"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Cookies     []harNameValue `json:"cookies"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime string                 `json:"startedDateTime"`
+	Time            float64                `json:"time"`
+	Request         harRequest             `json:"request"`
+	Response        harResponse            `json:"response"`
+	Cache           map[string]interface{} `json:"cache"`
+	Timings         harTimings             `json:"timings"`
+}
+
+type harLog struct {
+	Version string       `json:"version"`
+	Creator harNameValue `json:"creator"`
+	Entries []harEntry   `json:"entries"`
+}
+
+// harRecorder records HTTP request/response pairs to a HAR (HTTP Archive 1.2) file, with
+// credentials redacted. The file is rewritten after each request, so that it is complete
+// whenever the action exits.
+type harRecorder struct {
+	path string
+	mu   sync.Mutex
+	log  harLog
+}
+
+// networkTrace is the recorder of the HTTP traffic of all clients created by newHTTPClient
+// (nil if disabled)
+var networkTrace *harRecorder
+
+func newHARRecorder(path string) *harRecorder {
+	return &harRecorder{
+		path: path,
+		log: harLog{
+			Version: "1.2",
+			Creator: harNameValue{Name: "notarize-and-verify-pr-action", Value: "1.0"},
+			Entries: []harEntry{},
+		},
+	}
+}
+
+func (r *harRecorder) record(entry harEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log.Entries = append(r.log.Entries, entry)
+	harJSON, err := json.MarshalIndent(map[string]interface{}{"log": r.log}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error JSON-marshaling network trace: %v", err)
+	}
+	if err := ioutil.WriteFile(r.path, harJSON, 0600); err != nil {
+		return fmt.Errorf("error writing network trace file %s: %v", r.path, err)
+	}
+	return nil
+}
+
+// harTransport records the requests sent through the base transport.
+type harTransport struct {
+	recorder *harRecorder
+	base     http.RoundTripper
+}
+
+func (t *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		if requestBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	start := time.Now()
+	response, err := t.base.RoundTrip(req)
+	wait := time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+	if err != nil {
+		return nil, err
+	}
+	receive := time.Since(start) - wait
+
+	entry := harEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Time:            float64(time.Since(start)) / float64(time.Millisecond),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNameValue{},
+			Cookies:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(requestBody),
+		},
+		Response: harResponse{
+			Status:      response.StatusCode,
+			StatusText:  http.StatusText(response.StatusCode),
+			HTTPVersion: response.Proto,
+			Headers:     harHeaders(response.Header),
+			Cookies:     []harNameValue{},
+			Content: harContent{
+				Size:     len(responseBody),
+				MimeType: response.Header.Get("Content-Type"),
+				Text:     redactJSON(responseBody),
+			},
+			HeadersSize: -1,
+			BodySize:    len(responseBody),
+		},
+		Cache: map[string]interface{}{},
+		Timings: harTimings{
+			Wait:    float64(wait) / float64(time.Millisecond),
+			Receive: float64(receive) / float64(time.Millisecond),
+		},
+	}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: name, Value: value})
+		}
+	}
+	if len(requestBody) > 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     redactJSON(requestBody),
+		}
+	}
+	if err := t.recorder.record(entry); err != nil {
+		logger.Warn(fmt.Sprintf("WARNING: %v", err))
+	}
+	return response, nil
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+				value = redacted
+			}
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func redactJSON(body []byte) string {
+	return sensitiveJSONFields.ReplaceAllString(string(body), `"$1":"`+redacted+`"`)
+}
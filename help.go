@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const helpCmd = "--help"
+
+// usageHelp returns the usage of the action, with its args (which can also be set with
+// env vars, see README.md) and its exit codes.
+func usageHelp() string {
+	return fmt.Sprintf(`Usage: %s <CNIL host> <CNIL gRPC port> <CNIL gRPC no TLS> <PR approver>
+           [<CNIL API keys> <CNIL REST port> <CNIL personal token> <CNIL ledger ID>
+           <required PR approvers>] [flags]
+       %s %s
+       %s %s <ledger ID> -cnil-host <host> [-cnil-http-port <port>] [-yes]
+
+Notarizes the PR for the current approver and verifies that it is notarized by all the
+required approvers.
+
+Flags:
+   %s, %s <path>, %s, %s <n>, %s,
+   %s, %s, %s, %s <file>,
+   %s <type>, %s
+
+%s
+`,
+		filepath.Base(os.Args[0]),
+		filepath.Base(os.Args[0]), versionCmd,
+		filepath.Base(os.Args[0]), deleteAllKeysCmd,
+		dryRunFlag, repoPathFlag, fromCodeownersFlag, minApprovalsFlag, includeGitLogSummaryFlag,
+		notarizeLockfilesFlag, githubAppAuthFlag, outputApproverKeysFlag, ledgerStateAssertionFlag,
+		cnilLedgerTypeFlag, githubAttestationFlag,
+		exitCodesHelp())
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+var (
+	// hookStdout is the writer of the standard output of the hooks: the standard error in
+	// JSON and quiet modes, where the standard output is reserved to the action result.
+	hookStdout io.Writer = os.Stdout
+	// hookMutex serializes the hooks, as the verify hooks are run by the concurrent
+	// verifications of the approvers.
+	hookMutex sync.Mutex
+)
+
+// runHook runs the specified shell command with the action environment and the specified
+// additional env vars, forwarding its output to the action output. Only one hook runs at
+// a time.
+func runHook(name string, command string, env map[string]string) error {
+	hookMutex.Lock()
+	defer hookMutex.Unlock()
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdout = hookStdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running %s \"%s\": %v", name, command, err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	inToto "github.com/in-toto/in-toto-golang/in_toto"
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+const inTotoStepName = "notarize-pr"
+
+// writeInTotoLink writes an in-toto link metadata file (https://in-toto.io) for the
+// "review" step, with the artifact as material, signed with the specified PEM-encoded
+// PKCS#8 private key (Ed25519, ECDSA or RSA). The file is written to outputDir and named
+// following the in-toto convention <step name>.<key ID prefix>.link; its path is returned.
+func writeInTotoLink(artifact *vcnAPI.Artifact, signingKeyPEM []byte, outputDir string) (string, error) {
+	var key inToto.Key
+	if err := key.LoadKeyReaderDefaults(bytes.NewReader(signingKeyPEM)); err != nil {
+		return "", fmt.Errorf("error loading in-toto signing key: %v", err)
+	}
+
+	link := inToto.Metablock{
+		Signed: inToto.Link{
+			Type: "link",
+			Name: inTotoStepName,
+			Materials: map[string]interface{}{
+				artifact.Name: inToto.HashObj{"sha256": artifact.Hash},
+			},
+			Products:    map[string]interface{}{},
+			ByProducts:  map[string]interface{}{},
+			Command:     []string{},
+			Environment: map[string]interface{}{},
+		},
+	}
+	if err := link.Sign(key); err != nil {
+		return "", fmt.Errorf("error signing in-toto link: %v", err)
+	}
+
+	linkPath := filepath.Join(outputDir, fmt.Sprintf(inToto.LinkNameFormat, inTotoStepName, key.KeyID))
+	if err := link.Dump(linkPath); err != nil {
+		return "", fmt.Errorf("error writing in-toto link file %s: %v", linkPath, err)
+	}
+	return linkPath, nil
+}
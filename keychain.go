@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/keyring"
+)
+
+const keychainService = "codenotary-cnil"
+
+// keychainAPIKey reads the CNIL API key of the specified signer from the OS keychain: the
+// macOS Keychain, the Secret Service of Linux desktops (e.g. GNOME Keyring or KWallet) or
+// the Windows Credential Manager. The key is stored with the "codenotary-cnil" service and
+// the signer ID as key.
+func keychainAPIKey(signerID string) (string, error) {
+	ring, err := keyring.Open(keyring.Config{
+		ServiceName: keychainService,
+		AllowedBackends: []keyring.BackendType{
+			keyring.KeychainBackend,
+			keyring.SecretServiceBackend,
+			keyring.WinCredBackend,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error opening the OS keychain: %v", err)
+	}
+	item, err := ring.Get(signerID)
+	if errors.Is(err, keyring.ErrKeyNotFound) {
+		return "", errors.New("no API key of " + signerID + " found in the OS keychain")
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading API key of %s from the OS keychain: %v", signerID, err)
+	}
+	apiKey := strings.TrimSpace(string(item.Data))
+	if len(apiKey) == 0 {
+		return "", errors.New("no API key of " + signerID + " found in the OS keychain")
+	}
+	return apiKey, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+// The CNIL ledger backend types, see newLedgerClient.
+const (
+	ledgerTypeImmudb     = "immudb"
+	ledgerTypePostgreSQL = "postgresql"
+)
+
+// ledgerClient isolates the behaviors of the CNIL APIs which depend on the backend type of
+// the ledger.
+type ledgerClient interface {
+	// loadArtifact loads the artifact with the specified hash from the ledger, and returns
+	// nil if it is not found.
+	loadArtifact(vcnCNILUser *vcnAPI.LcUser, hash string) (*vcnAPI.LcArtifact, error)
+	// stateURL returns the URL of the state of the ledger in the CNIL REST API, or an error
+	// if the backend has no block height to assert.
+	stateURL(baseURL string, ledgerID string) (string, error)
+}
+
+// immudbLedgerClient is the client of the ledgers backed by immudb, whose reads are
+// verified with Merkle proofs.
+type immudbLedgerClient struct{}
+
+func (immudbLedgerClient) loadArtifact(vcnCNILUser *vcnAPI.LcUser, hash string) (*vcnAPI.LcArtifact, error) {
+	cnilArtifact, verified, err := vcnCNILUser.LoadArtifact(hash, "", "", 0)
+	if err == vcnAPI.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ledger might be compromised: %v", err)
+	}
+	if !verified {
+		return nil, errLedgerInconsistent
+	}
+	return cnilArtifact, nil
+}
+
+func (immudbLedgerClient) stateURL(baseURL string, ledgerID string) (string, error) {
+	return fmt.Sprintf("%s/ledgers/%s/state", baseURL, ledgerID), nil
+}
+
+// postgreSQLLedgerClient is the client of the ledgers backed by PostgreSQL, which provide
+// neither the verified status of the artifacts nor a block height.
+type postgreSQLLedgerClient struct{}
+
+func (postgreSQLLedgerClient) loadArtifact(vcnCNILUser *vcnAPI.LcUser, hash string) (*vcnAPI.LcArtifact, error) {
+	// there is no Merkle proof to verify
+	cnilArtifact, _, err := vcnCNILUser.LoadArtifact(hash, "", "", 0)
+	if err == vcnAPI.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading artifact %s: %v", hash, err)
+	}
+	return cnilArtifact, nil
+}
+
+func (postgreSQLLedgerClient) stateURL(baseURL string, ledgerID string) (string, error) {
+	return "", fmt.Errorf("the state of ledger %s cannot be asserted: %s ledgers have no block height",
+		ledgerID, ledgerTypePostgreSQL)
+}
+
+// newLedgerClient returns the client of the specified CNIL ledger backend type (immudb if
+// empty).
+func newLedgerClient(ledgerType string) (ledgerClient, error) {
+	switch strings.ToLower(ledgerType) {
+	case "", ledgerTypeImmudb:
+		return immudbLedgerClient{}, nil
+	case ledgerTypePostgreSQL:
+		return postgreSQLLedgerClient{}, nil
+	default:
+		return nil, fmt.Errorf("invalid CNIL ledger type \"%s\": expected %s or %s",
+			ledgerType, ledgerTypeImmudb, ledgerTypePostgreSQL)
+	}
+}
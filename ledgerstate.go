@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// errLedgerRollback is returned by checkLedgerRollback if the ledger is behind its baseline
+// state, which is a critical integrity violation.
+var errLedgerRollback = errors.New("ledger rollback detected")
+
+// LedgerStateResponse is the state of a CNIL ledger, as returned by GET
+// /ledgers/{id}/state, which is also the format of the baseline ledger state file.
+type LedgerStateResponse struct {
+	LedgerID    string `json:"ledgerId,omitempty"`
+	BlockHeight uint64 `json:"blockHeight"`
+}
+
+// fetchLedgerState fetches the current state of the ledger from the CNIL REST API, which
+// is not available for all the ledger backend types.
+func fetchLedgerState(options *cnilOptions) (*LedgerStateResponse, error) {
+	url, err := options.ledger.stateURL(options.baseURL, options.ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	responsePayload := LedgerStateResponse{}
+	if err := sendHTTPRequest(
+		options.httpClient,
+		options.retryPolicy,
+		http.MethodGet,
+		url,
+		options.token,
+		http.StatusOK,
+		nil,
+		&responsePayload,
+	); err != nil {
+		return nil, fmt.Errorf("error getting the state of ledger %s: %v", options.ledgerID, err)
+	}
+	return &responsePayload, nil
+}
+
+// readLedgerState reads a ledger state previously exported to the specified file.
+func readLedgerState(path string) (*LedgerStateResponse, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading baseline ledger state file %s: %v", path, err)
+	}
+	state := &LedgerStateResponse{}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("error JSON-unmarshaling baseline ledger state file %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// checkLedgerRollback returns errLedgerRollback if the current block height of the ledger
+// is lower than the baseline one, i.e. if the ledger has been rolled back since the
+// baseline state was exported. A baseline of another ledger is an error.
+func checkLedgerRollback(baseline *LedgerStateResponse, current *LedgerStateResponse, ledgerID string) error {
+	if len(baseline.LedgerID) > 0 && baseline.LedgerID != ledgerID {
+		return fmt.Errorf("the baseline ledger state is the one of ledger %s, not of ledger %s",
+			baseline.LedgerID, ledgerID)
+	}
+	if current.BlockHeight < baseline.BlockHeight {
+		return fmt.Errorf("%w: the block height of ledger %s is %d, lower than the baseline height %d",
+			errLedgerRollback, ledgerID, current.BlockHeight, baseline.BlockHeight)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// dependencyLockfileNames are the names of the files pinning the dependencies of the
+// supported package managers (npm, Poetry, Go modules and Maven).
+var dependencyLockfileNames = map[string]struct{}{
+	"package-lock.json": {},
+	"poetry.lock":       {},
+	"go.sum":            {},
+	"pom.xml":           {},
+}
+
+// lockfileSkippedDirs are the directories not searched for dependency lockfiles, as they
+// hold the repository metadata or the dependencies themselves.
+var lockfileSkippedDirs = map[string]struct{}{
+	".git":         {},
+	"node_modules": {},
+	"vendor":       {},
+}
+
+// detectAndHashLockfiles searches the workspace for dependency lockfiles and returns the
+// hex-encoded SHA256 hash of each of them, by path relative to the workspace. The files
+// which cannot be read are skipped with a warning.
+func detectAndHashLockfiles(workspacePath string) map[string]string {
+	hashes := make(map[string]string)
+	filepath.Walk(workspacePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: error searching %s for dependency lockfiles: %v", path, err))
+			return nil
+		}
+		if info.IsDir() {
+			if _, ok := lockfileSkippedDirs[info.Name()]; ok && path != workspacePath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := dependencyLockfileNames[info.Name()]; !ok || !info.Mode().IsRegular() {
+			return nil
+		}
+		hash, err := fileSHA256(path)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: error hashing dependency lockfile: %v", err))
+			return nil
+		}
+		relativePath, err := filepath.Rel(workspacePath, path)
+		if err != nil {
+			relativePath = path
+		}
+		hashes[filepath.ToSlash(relativePath)] = hash
+		return nil
+	})
+	return hashes
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestDetectAndHashLockfiles(t *testing.T) {
+	sha256Hex := func(content string) string {
+		hash := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(hash[:])
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  map[string]string
+	}{
+		{
+			name: "lockfiles at any depth",
+			files: map[string]string{
+				"go.sum":                      "go.sum content",
+				"web/package-lock.json":       "{}",
+				"services/api/poetry.lock":    "poetry",
+				"services/java/pom.xml":       "<project/>",
+				"web/package.json":            "{}",
+				"services/api/pyproject.toml": "[tool.poetry]",
+			},
+			want: map[string]string{
+				"go.sum":                   sha256Hex("go.sum content"),
+				"web/package-lock.json":    sha256Hex("{}"),
+				"services/api/poetry.lock": sha256Hex("poetry"),
+				"services/java/pom.xml":    sha256Hex("<project/>"),
+			},
+		},
+		{
+			name: "skipped directories",
+			files: map[string]string{
+				"package-lock.json":                  "root",
+				"node_modules/dep/package-lock.json": "dep",
+				"vendor/github.com/dep/go.sum":       "vendored",
+				".git/go.sum":                        "git",
+			},
+			want: map[string]string{
+				"package-lock.json": sha256Hex("root"),
+			},
+		},
+		{
+			name:  "no lockfile",
+			files: map[string]string{"main.go": "package main"},
+			want:  map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			writeTestFiles(t, workspace, test.files)
+			if got := detectAndHashLockfiles(workspace); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("detectAndHashLockfiles() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+	"google.golang.org/grpc"
+)
+
+// levelSuccess is the level of the success messages (printed in green), between the info
+// and the warning levels.
+const levelSuccess = slog.Level(2)
+
+// ANSI color of the debug messages (faint), the other levels use the red, yellow and green
+// formats.
+const faint = "\033[2m%s\033[0m"
+
+// colorsEnabled is true if the output may contain ANSI color codes (see colorsSupported).
+var colorsEnabled = true
+
+// colorsSupported returns false if the NO_COLOR env var is set (https://no-color.org), if
+// TERM is "dumb", or if the standard output is not a terminal. GITHUB_ACTIONS=true (set by
+// the GitHub Actions runners) overrides the terminal check: the output of the action is
+// piped to the workflow run logs, which render the ANSI colors.
+func colorsSupported() bool {
+	if len(os.Getenv("NO_COLOR")) > 0 || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if getEnvBool("GITHUB_ACTIONS") {
+		return true
+	}
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// colorize formats the args with the color format (e.g. red), or returns them as plain
+// text if the colors are disabled.
+func colorize(format string, args ...interface{}) string {
+	if !colorsEnabled {
+		return fmt.Sprint(args...)
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// logger is the logger of the action messages (see the ACTION_LOG_LEVEL env var).
+var logger = slog.New(newColorTextHandler(os.Stdout, slog.LevelInfo))
+
+// parseLogLevel parses the log level of the action messages: "debug", "info" (the
+// default), "warn" or "error".
+func parseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unsupported log level \"%s\": expected debug, info, warn or error", level)
+	}
+}
+
+// logSuccess logs a success message.
+func logSuccess(msg string) {
+	logger.Log(context.Background(), levelSuccess, msg)
+}
+
+// colorTextHandler is the slog handler of the default (text) output: it writes the
+// messages as is (without time nor level), in the color of their level, followed by their
+// attributes (if any).
+type colorTextHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+	attrs string
+}
+
+func newColorTextHandler(w io.Writer, level slog.Leveler) *colorTextHandler {
+	return &colorTextHandler{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (h *colorTextHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *colorTextHandler) Handle(_ context.Context, record slog.Record) error {
+	attrs := h.attrs
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs += " " + attr.String()
+		return true
+	})
+	msg := strings.TrimSuffix(record.Message, "\n") + attrs
+	switch {
+	case record.Level >= slog.LevelError:
+		msg = colorize(red, msg)
+	case record.Level >= slog.LevelWarn:
+		msg = colorize(yellow, msg)
+	case record.Level >= levelSuccess:
+		msg = colorize(green, msg)
+	case record.Level < slog.LevelInfo:
+		msg = colorize(faint, msg)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, msg+"\n")
+	return err
+}
+
+func (h *colorTextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	for _, attr := range attrs {
+		handler.attrs += " " + attr.String()
+	}
+	return &handler
+}
+
+// WithGroup is not supported: the attributes of the groups are not qualified.
+func (h *colorTextHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+// newJSONLogger creates the logger of the JSON output mode, which writes JSON lines with
+// the "time", "level" ("debug", "info", "warning" or "error") and "message" keys.
+func newJSONLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return attr
+			}
+			switch attr.Key {
+			case slog.TimeKey:
+				attr.Value = slog.TimeValue(attr.Value.Time().UTC())
+			case slog.MessageKey:
+				attr.Key = "message"
+			case slog.LevelKey:
+				attr.Value = slog.StringValue(jsonLogLevel(attr.Value.Any().(slog.Level)))
+			}
+			return attr
+		},
+	}))
+}
+
+// lastErrorHandler passes the messages on to the wrapped handler, and records the last
+// error message, e.g. for the JSON result of a failed action.
+type lastErrorHandler struct {
+	slog.Handler
+	last *atomic.Value
+}
+
+func newLastErrorHandler(handler slog.Handler) *lastErrorHandler {
+	return &lastErrorHandler{Handler: handler, last: &atomic.Value{}}
+}
+
+func (h *lastErrorHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelError {
+		h.last.Store(strings.TrimSpace(record.Message))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *lastErrorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &lastErrorHandler{Handler: h.Handler.WithAttrs(attrs), last: h.last}
+}
+
+func (h *lastErrorHandler) WithGroup(name string) slog.Handler {
+	return &lastErrorHandler{Handler: h.Handler.WithGroup(name), last: h.last}
+}
+
+// lastError returns the last error message (if any).
+func (h *lastErrorHandler) lastError() string {
+	message, _ := h.last.Load().(string)
+	return message
+}
+
+// jsonLogLevel returns the name of the level in the JSON output mode (the success messages
+// are info messages).
+func jsonLogLevel(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warning"
+	case level >= slog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// debugTransport logs the method, URL (without the query, which may hold secrets), status
+// and duration of the HTTP requests sent through the base transport.
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	response, err := t.base.RoundTrip(req)
+	requestURL := *req.URL
+	requestURL.RawQuery = ""
+	if err != nil {
+		logger.Debug(fmt.Sprintf("HTTP %s %s failed after %s: %v",
+			req.Method, requestURL.String(), time.Since(start), err))
+		return nil, err
+	}
+	logger.Debug(fmt.Sprintf("HTTP %s %s: %s in %s",
+		req.Method, requestURL.String(), response.Status, time.Since(start)))
+	return response, nil
+}
+
+// debugUnaryInterceptor logs the method, outcome and duration of the gRPC calls.
+func debugUnaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		logger.Debug(fmt.Sprintf("gRPC %s failed after %s: %v", method, time.Since(start), err))
+		return err
+	}
+	logger.Debug(fmt.Sprintf("gRPC %s: OK in %s", method, time.Since(start)))
+	return nil
+}
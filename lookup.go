@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type SignerLookupResponse struct {
+	APIKey string `json:"api_key"`
+}
+
+// lookupExternalSignerKey gets the CNIL API key of the specified GitHub user from an
+// external key management service, with GET <lookupURL>?username=<username>. The
+// lookupToken (if any) is sent as a bearer token.
+func lookupExternalSignerKey(username, lookupURL, lookupToken string) (string, error) {
+	parsedURL, err := url.Parse(lookupURL)
+	if err != nil {
+		return "", fmt.Errorf("error parsing signer lookup URL %s: %v", lookupURL, err)
+	}
+	query := parsedURL.Query()
+	query.Set("username", username)
+	parsedURL.RawQuery = query.Encode()
+
+	responsePayload := SignerLookupResponse{}
+	if err := sendHTTPRequest(
+		newHTTPClient(&tls.Config{MinVersion: tls.VersionTLS12}),
+		nil,
+		http.MethodGet,
+		parsedURL.String(),
+		lookupToken,
+		http.StatusOK,
+		nil,
+		&responsePayload,
+	); err != nil {
+		return "", err
+	}
+	if len(responsePayload.APIKey) == 0 {
+		return "", errors.New("the signer lookup service returned an empty API key")
+	}
+	return responsePayload.APIKey, nil
+}
@@ -2,19 +2,30 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+	vcnDirExtractor "github.com/vchain-us/vcn/pkg/extractor/dir"
 	vcnGitExtractor "github.com/vchain-us/vcn/pkg/extractor/git"
 	vcnMeta "github.com/vchain-us/vcn/pkg/meta"
 	vcnStore "github.com/vchain-us/vcn/pkg/store"
@@ -22,9 +33,37 @@ import (
 )
 
 const (
-	pathToRepo     = "/github/workspace"
-	identitySuffix = "@github"
-	httpTimeout    = 30 * time.Second
+	outputApproverKeysFlag    = "--output-approver-keys"
+	outputApproverKeysConfirm = "yes-i-understand-this-is-insecure"
+	dryRunFlag                = "--dry-run"
+	minApprovalsFlag          = "--min-approvals"
+	repoPathFlag              = "--repo-path"
+	fromCodeownersFlag        = "--from-codeowners"
+	includeGitLogSummaryFlag  = "--include-git-log-summary"
+	notarizeLockfilesFlag     = "--notarize-dependency-lockfiles"
+	githubAppAuthFlag         = "--github-app-auth"
+	ledgerStateAssertionFlag  = "--ledger-state-assertion"
+	cnilLedgerTypeFlag        = "--cnil-ledger-type"
+	githubAttestationFlag     = "--create-github-attestation"
+)
+
+const (
+	defaultPathToRepo     = "/github/workspace"
+	vcnStoreDir           = "./.vcn"
+	defaultIdentitySuffix = "@github"
+
+	// HTTP requests timeout, configurable with the ACTION_HTTP_TIMEOUT env var
+	defaultHTTPTimeout = 30 * time.Second
+	maxHTTPTimeout     = 5 * time.Minute
+
+	maxArtifactLabelLen = 256
+)
+
+// Supported NOTARIZE_IF_ALREADY_SIGNED values
+const (
+	alreadySignedSkip   = "skip"
+	alreadySignedFail   = "fail"
+	alreadySignedResign = "resign"
 )
 
 const (
@@ -33,8 +72,22 @@ const (
 	yellow = "\033[1;33m%s\033[0m"
 )
 
+// pathToRepo is the path to the git repository of the PR (see the ACTION_REPO_PATH env var).
+var pathToRepo = defaultPathToRepo
+
+// identitySuffix is appended to the approver usernames to get their signer IDs (see the
+// ACTION_IDENTITY_SUFFIX env var).
+var identitySuffix = defaultIdentitySuffix
+
 var (
 	errAPIKeyNotFound = errors.New("API key not found")
+	// errLedgerInconsistent is returned by verify if the CNIL (immudb) verification of the
+	// artifact failed, which can be a transient consistency check failure
+	errLedgerInconsistent = errors.New(
+		`ledger might be compromised: CNIL verification status is "false"`)
+	// errNotarizationExpired is returned by checkNotarizationAge if the notarization is older
+	// than the maximum age, in which case verify treats the artifact as not notarized
+	errNotarizationExpired = errors.New("notarization expired")
 )
 
 // Expects args:
@@ -47,35 +100,523 @@ var (
 //	- CNIL REST API personal token (required if CNIL API key is empty)
 //	- CNIL ledger ID (required if CNIL API key is empty)
 //	- comma-separated list of required PR approvers (GitHub usernames) (required if CNIL API key is empty)
+//
+// Empty or omitted args can be set with env vars instead: ACTION_CNIL_HOST, ACTION_CNIL_PORT,
+// ACTION_CNIL_NO_TLS, ACTION_APPROVER, ACTION_CNIL_TOKEN, ACTION_CNIL_LEDGER_ID and
+// ACTION_REQUIRED_APPROVERS (ACTION_CNIL_URL overrides the CNIL REST API server URL).
+//
+// Alternatively, all API keys of a ledger can be deleted using:
+//
+//	--delete-all-keys <ledger ID> -cnil-host <host> [-cnil-http-port <port>] [-yes]
+//
+// (the CNIL REST API personal token is read from the CNIL_PERSONAL_TOKEN env var)
+//
+// The --version arg prints the version of the vcn library the action is compiled with.
+//
+// The --help arg prints the usage of the action, along with its exit codes (see ExitCode).
+//
+// The --output-approver-keys flag can be added to the args to print the API keys of the
+// required approvers after the key rotation, for debugging purposes (requires
+// OUTPUT_KEYS_CONFIRM=yes-i-understand-this-is-insecure).
+//
+// The --dry-run flag (or ACTION_DRY_RUN=true) verifies the PR without notarizing it.
+//
+// The --repo-path <path> flag (or ACTION_REPO_PATH=<path>) sets the path to the git
+// repository of the PR (/github/workspace by default).
+//
+// The --from-codeowners flag (or ACTION_FROM_CODEOWNERS=true) adds the code owners of the
+// files changed by the PR to the required approvers.
+//
+// The --min-approvals <n> flag (or ACTION_MIN_APPROVALS=<n>) only requires n of the required
+// approvers to have notarized the PR, instead of all of them.
+//
+// The --include-git-log-summary flag (or ACTION_INCLUDE_GIT_LOG_SUMMARY=true) adds the
+// summaries of the PR commits to the metadata of the notarization.
+//
+// The --notarize-dependency-lockfiles flag (or ACTION_NOTARIZE_DEPENDENCY_LOCKFILES=true)
+// adds the hashes of the dependency lockfiles to the metadata of the notarization.
+//
+// The --github-app-auth flag (or ACTION_GITHUB_APP_AUTH=true) authenticates the GitHub API
+// calls as a GitHub App installation (see the GITHUB_APP_* env vars).
+//
+// The --ledger-state-assertion <file> flag (or BASELINE_LEDGER_STATE_FILE=<file>) fails if
+// the ledger has been rolled back since the baseline ledger state was exported.
+//
+// The --cnil-ledger-type <type> flag (or CNIL_LEDGER_TYPE=<type>) sets the backend type of
+// the CNIL ledger: immudb (default) or postgresql, whose reads are not verified.
+//
+// The --create-github-attestation flag (or CREATE_GITHUB_ATTESTATION=true) submits the
+// Sigstore bundle of the PR (SIGSTORE_BUNDLE_PATH) to the GitHub Attestations API once the
+// PR is notarized.
 func main() {
+	os.Exit(int(runAction(run)))
+}
+
+// run runs the action, until it returns or calls exitWith.
+func run() {
+
+	// disable the ANSI colors where they are not supported
+	colorsEnabled = colorsSupported()
+
+	// the help and the version do not depend on the configuration, which is not validated
+	if len(os.Args) > 1 && os.Args[1] == helpCmd {
+		fmt.Print(usageHelp())
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == versionCmd {
+		fmt.Printf("vcn library version: %s\n", vcnLibraryVersion())
+		return
+	}
+
+	// filter the messages by level (info by default)
+	logLevel, logLevelErr := parseLogLevel(os.Getenv("ACTION_LOG_LEVEL"))
+	if logLevelErr != nil {
+		logger.Error(fmt.Sprintf("ABORTING: invalid ACTION_LOG_LEVEL: %v", logLevelErr))
+		exitWith(ExitInvalidArgs)
+	}
+	logger = slog.New(newColorTextHandler(os.Stdout, logLevel))
+	slog.SetDefault(logger)
+
+	// record the HTTP traffic for debugging purposes (if enabled)
+	if traceFile := strings.TrimSpace(os.Getenv("NETWORK_TRACE_FILE")); len(traceFile) > 0 {
+		networkTrace = newHARRecorder(traceFile)
+	}
+
+	httpTimeout = getEnvDuration("ACTION_HTTP_TIMEOUT", defaultHTTPTimeout)
+	if httpTimeout <= 0 || httpTimeout > maxHTTPTimeout {
+		logger.Error(fmt.Sprintf(
+			"ABORTING: ACTION_HTTP_TIMEOUT (%s) must be positive and must not exceed %s",
+			httpTimeout, maxHTTPTimeout))
+		exitWith(ExitInvalidArgs)
+	}
+	if proxy := strings.TrimSpace(os.Getenv("ACTION_HTTP_PROXY")); len(proxy) > 0 {
+		var err error
+		if httpProxyURL, err = parseHTTPProxy(proxy); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitInvalidArgs)
+		}
+	}
+
+	// make sure the CNIL REST API responses are authentic (if required)
+	if keyFile := strings.TrimSpace(os.Getenv("CNIL_RESPONSE_SIGNING_PUBLIC_KEY")); len(keyFile) > 0 {
+		publicKey, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: error reading CNIL response signing public key file %s: %v", keyFile, err))
+			exitWith(ExitInvalidArgs)
+		}
+		cnilResponseSigningKey = publicKey
+	}
+
+	// pin the CNIL REST API version in all requests
+	if version := strings.TrimSpace(os.Getenv("CNIL_API_VERSION")); len(version) > 0 {
+		if n, err := strconv.ParseUint(version, 10, 64); err != nil || n == 0 {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: invalid CNIL_API_VERSION value \"%s\": expected a positive integer", version))
+			exitWith(ExitInvalidArgs)
+		}
+		cnilAPIVersion = version
+	}
+
+	// use another git repository path than the GitHub Actions workspace (if specified), e.g.
+	// on self-hosted runners or for local testing
+	if value, ok := flagValue(repoPathFlag); ok {
+		os.Setenv("ACTION_REPO_PATH", value)
+	}
+	if repoPath := strings.TrimSpace(os.Getenv("ACTION_REPO_PATH")); len(repoPath) > 0 {
+		pathToRepo = repoPath
+	}
+
+	// use the identity suffix of another SCM than GitHub (if specified)
+	if suffix := strings.TrimSpace(os.Getenv("ACTION_IDENTITY_SUFFIX")); len(suffix) > 0 {
+		if !strings.HasPrefix(suffix, "@") {
+			logger.Warn(fmt.Sprintf(
+				"WARNING: ACTION_IDENTITY_SUFFIX \"%s\" does not start with @, as the VCN signer IDs usually do",
+				suffix))
+		}
+		identitySuffix = suffix
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == deleteAllKeysCmd {
+		if err := runDeleteAllKeys(os.Args[2:]); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitAPIError)
+		}
+		return
+	}
+
+	// authenticate the GitHub API calls as a GitHub App installation instead of with a
+	// long-lived token (if enabled): the installation token replaces GITHUB_TOKEN
+	githubAppAuth := getEnvBool("ACTION_GITHUB_APP_AUTH")
+	if hasFlag(githubAppAuthFlag) {
+		githubAppAuth = true
+	}
+	if githubAppAuth {
+		token, err := gitHubAppTokenFromEnv()
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: GitHub App authentication error: %v", err))
+			exitWith(ExitAPIError)
+		}
+		os.Setenv("GITHUB_TOKEN", token)
+		logger.Info(fmt.Sprintf("Authenticated as installation %s of GitHub App %s",
+			strings.TrimSpace(os.Getenv("GITHUB_APP_INSTALLATION_ID")), strings.TrimSpace(os.Getenv("GITHUB_APP_ID"))))
+	}
+
+	quiet := getEnvBool("QUIET")
+	jsonOutput := strings.EqualFold(strings.TrimSpace(os.Getenv("ACTION_OUTPUT_FORMAT")), "json")
+	commitStatus := getEnvBool("ACTION_SET_COMMIT_STATUS")
+
+	// notarize and verify each artifact of the artifacts manifest in turn (if specified),
+	// otherwise the one configured
+	artifactsManifest := strings.TrimSpace(os.Getenv("ARTIFACTS_MANIFEST"))
+	artifactPaths := []string{strings.TrimSpace(os.Getenv("ARTIFACT_PATH"))}
+	if len(artifactsManifest) > 0 {
+		var err error
+		if artifactPaths, err = readArtifactsManifest(artifactsManifest, pathToRepo); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitInvalidArgs)
+		}
+	}
+
+	// in JSON mode, the messages are JSON lines on the standard error, and the JSON results
+	// are the only output on the standard output; in quiet mode, the messages are buffered
+	// until the action ends (see printQuietOutput)
+	var quietOutput bytes.Buffer
+	var handler slog.Handler
+	switch {
+	case jsonOutput:
+		handler = newJSONLogger(os.Stderr, logLevel).Handler()
+	case quiet:
+		handler = newColorTextHandler(&quietOutput, logLevel)
+	default:
+		handler = logger.Handler()
+	}
+	if jsonOutput || quiet {
+		hookStdout = os.Stderr
+	}
+	// the last error message is the error of the result of a failed action
+	errorHandler := newLastErrorHandler(handler)
+	logger = slog.New(errorHandler)
+	slog.SetDefault(logger)
+
+	// replace the CNIL API calls with in-memory stubs (if enabled): the mock results are
+	// not published, e.g. as commit status
+	cnilMockMode = getEnvBool("CNIL_MOCK_MODE")
+	if cnilMockMode {
+		logger.Warn("WARNING: CNIL mock mode is enabled, nothing is notarized in any CNIL ledger " +
+			"and the result is not published")
+		commitStatus = false
+	}
 
-	// validate number of inputs
+	// set the commit status to pending until the action ends (if enabled)
+	var githubAPIOptions *githubOptions
+	var sha string
+	if commitStatus {
+		var err error
+		if githubAPIOptions, err = newGitHubOptions(newHTTPClient(nil)); err == nil {
+			if sha, err = gitHeadCommit(pathToRepo); err == nil {
+				err = setCommitStatus(githubAPIOptions, sha, "pending", "Verifying the PR notarizations")
+			}
+		}
+		if err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: error setting the commit status: %v", err))
+			githubAPIOptions = nil
+		}
+	}
+
+	// the result of the verification of all the artifacts (if the action gets that far)
+	var result *notarizationResult
+	report := func(verificationResult *notarizationResult) {
+		result = verificationResult
+	}
+	exitCode := runAction(func() {
+		// remove the VCN store when the action ends, whatever the outcome (if enabled)
+		if getEnvBool("CLEANUP_VCN_STORE") {
+			defer func() {
+				if err := os.RemoveAll(vcnStoreDir); err != nil {
+					logger.Warn(fmt.Sprintf(
+						"WARNING: error removing VCN local store directory %s: %v", vcnStoreDir, err))
+				}
+			}()
+		}
+
+		// decrypt the VCN store at rest before using it, and encrypt it again when the
+		// action ends, whatever the outcome (if enabled)
+		if storeEncryptionKeyHex := os.Getenv("VCN_STORE_ENCRYPTION_KEY"); len(storeEncryptionKeyHex) > 0 {
+			storeEncryptionKey, err := parseStoreEncryptionKey(storeEncryptionKeyHex)
+			if err == nil {
+				err = decryptStore(vcnStoreEncryptedFile, vcnStoreDir, storeEncryptionKey)
+			}
+			if err != nil {
+				logger.Error(fmt.Sprintf("ABORTING: %v", err))
+				exitWith(ExitFailure)
+			}
+			defer func() {
+				if err := encryptStore(vcnStoreDir, vcnStoreEncryptedFile, storeEncryptionKey); err != nil {
+					logger.Warn(fmt.Sprintf("WARNING: error encrypting the VCN local store: %v", err))
+				}
+			}()
+		}
+
+		notarizeAndVerify(commitStatus, artifactPaths, report)
+	})
+	resultJSON := newActionResultJSON(exitCode, result, errorHandler.lastError())
+	resultJSON.Mock = cnilMockMode
+	if jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(resultJSON)
+	}
+
+	if githubAPIOptions != nil {
+		state, description := commitStatusOfResult(int(exitCode), resultJSON)
+		if err := setCommitStatus(githubAPIOptions, sha, state, description); err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: error setting the commit status: %v", err))
+		}
+	}
+	if quiet && !jsonOutput {
+		printQuietOutput(quietOutput.String(), exitCode == 0, quietSuccessMessage(resultJSON))
+	}
+	exitWith(exitCode)
+}
+
+// notarizeAndVerify gets the API keys of the required approvers once, then, for each of
+// the artifact paths in turn (the git repository of the PR if empty), notarizes the
+// artifact for the current approver (if required) and verifies that it has been notarized
+// by the required approvers. The results of all the artifacts are merged into a single one,
+// which the report function is called with (if the verification gets that far) before it
+// is published.
+func notarizeAndVerify(
+	commitStatus bool,
+	artifactPaths []string,
+	report func(result *notarizationResult),
+) {
+	outputApproverKeys := hasFlag(outputApproverKeysFlag)
+	dryRun := getEnvBool("ACTION_DRY_RUN")
+	if hasFlag(dryRunFlag) {
+		dryRun = true
+	}
+	fromCodeowners := getEnvBool("ACTION_FROM_CODEOWNERS")
+	if hasFlag(fromCodeownersFlag) {
+		fromCodeowners = true
+	}
+	includeGitLogSummary := getEnvBool("ACTION_INCLUDE_GIT_LOG_SUMMARY")
+	if hasFlag(includeGitLogSummaryFlag) {
+		includeGitLogSummary = true
+	}
+	notarizeLockfiles := getEnvBool("ACTION_NOTARIZE_DEPENDENCY_LOCKFILES")
+	if hasFlag(notarizeLockfilesFlag) {
+		notarizeLockfiles = true
+	}
+
+	createAttestation := getEnvBool("CREATE_GITHUB_ATTESTATION")
+	if hasFlag(githubAttestationFlag) {
+		createAttestation = true
+	}
+
+	// the ledger state previously exported, to detect a rollback of the ledger (if any)
+	baselineLedgerStateFile := strings.TrimSpace(os.Getenv("BASELINE_LEDGER_STATE_FILE"))
+	if value, ok := flagValue(ledgerStateAssertionFlag); ok {
+		baselineLedgerStateFile = strings.TrimSpace(value)
+	}
+
+	// the backend type of the CNIL ledger, immudb by default
+	cnilLedgerType := strings.TrimSpace(os.Getenv("CNIL_LEDGER_TYPE"))
+	if value, ok := flagValue(cnilLedgerTypeFlag); ok {
+		cnilLedgerType = strings.TrimSpace(value)
+	}
+	cnilLedger, err := newLedgerClient(cnilLedgerType)
+	if err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: %v", err))
+		exitWith(ExitInvalidArgs)
+	}
+
+	// the number of required approvers who must have notarized the PR (all by default)
+	minApprovalsStr := strings.TrimSpace(os.Getenv("ACTION_MIN_APPROVALS"))
+	if value, ok := flagValue(minApprovalsFlag); ok {
+		minApprovalsStr = strings.TrimSpace(value)
+	}
+	var minApprovals uint64
+	if len(minApprovalsStr) > 0 {
+		var err error
+		if minApprovals, err = strconv.ParseUint(minApprovalsStr, 10, 64); err != nil || minApprovals == 0 {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: invalid minimum number of approvals \"%s\": expected a positive integer",
+				minApprovalsStr))
+			exitWith(ExitInvalidArgs)
+		}
+	}
+	if outputApproverKeys && os.Getenv("OUTPUT_KEYS_CONFIRM") != outputApproverKeysConfirm {
+		logger.Error(fmt.Sprintf(
+			"ABORTING: %s prints sensitive API keys, set OUTPUT_KEYS_CONFIRM=%s to confirm",
+			outputApproverKeysFlag, outputApproverKeysConfirm))
+		exitWith(ExitInvalidArgs)
+	}
+
+	// validate number of inputs (trailing args can be omitted in favor of env vars)
 	expectedNbArgs := 9
-	if len(os.Args)-1 != expectedNbArgs {
-		fmt.Printf(red, fmt.Sprintf(
-			"invalid args %+v: expected %d, got %d\n", os.Args, expectedNbArgs, len(os.Args)-1))
-		os.Exit(1)
+	if len(os.Args)-1 > expectedNbArgs {
+		logger.Error(fmt.Sprintf(
+			"invalid args %+v: expected %d, got %d", os.Args, expectedNbArgs, len(os.Args)-1))
+		exitWith(ExitInvalidArgs)
 	}
 
 	// validate inputs
-	cnilHost := getArg(1, "CNIL host", true, "")
-	cnilgRPCPort := getArg(2, "CNIL gRPC API port", false, "443")
-	cnilNoTLS := getArg(3, "CNIL gRPC no TLS", false, "false")
-	approver := getArg(4, "PR approver", true, "")
-	cnilAPIKeysStr := getArg(5, "CNIL API key(s)", false, "")
-	cnilRESTPort := getArg(6, "CNIL REST API port", false, "443")
-	cnilToken := getArg(7, "CNIL REST API personal token", false, "")
-	cnilLedgerID := getArg(8, "CNIL ledger ID", false, "")
-	requiredApprovers := getArg(9, "required PR approvers", false, "")
-
-	cnilRESTURL := fmt.Sprintf("https://%s:%s/api/v1", cnilHost, cnilRESTPort)
+	cnilHost := getArg(1, "ACTION_CNIL_HOST", "CNIL host", true, "")
+	cnilgRPCPort := getArg(2, "ACTION_CNIL_PORT", "CNIL gRPC API port", false, "443")
+	cnilNoTLS := getArg(3, "ACTION_CNIL_NO_TLS", "CNIL gRPC no TLS", false, "false")
+	approver := getArg(4, "ACTION_APPROVER", "PR approver", true, "")
+	cnilAPIKeysStr := getArg(5, "", "CNIL API key(s)", false, "")
+	cnilRESTPort := getArg(6, "", "CNIL REST API port", false, "443")
+	cnilToken := getArg(7, "ACTION_CNIL_TOKEN", "CNIL REST API personal token", false, "")
+	cnilLedgerID := getArg(8, "ACTION_CNIL_LEDGER_ID", "CNIL ledger ID", false, "")
+	requiredApprovers := getArg(9, "ACTION_REQUIRED_APPROVERS", "required PR approvers", false, "")
+
+	// read the credentials from the mounted secret files (if specified and not set directly)
+	cnilToken, err = readFileOrDirect(cnilToken, strings.TrimSpace(os.Getenv("ACTION_CNIL_TOKEN_FILE")))
+	if err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: error reading the CNIL REST API personal token: %v", err))
+		exitWith(ExitInvalidArgs)
+	}
+	cnilAPIKeysStr, err = readFileOrDirect(cnilAPIKeysStr, strings.TrimSpace(os.Getenv("ACTION_CNIL_API_KEY_FILE")))
+	if err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: error reading the CNIL API key(s): %v", err))
+		exitWith(ExitInvalidArgs)
+	}
+	requiredApprovers, err = resolveApprovers(
+		requiredApprovers, strings.TrimSpace(os.Getenv("ACTION_APPROVERS_FILE")))
+	if err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: %v", err))
+		exitWith(ExitInvalidArgs)
+	}
+
+	// require the code owners of the files changed by the PR as well (if enabled)
+	if fromCodeowners {
+		baseBranch := os.Getenv("GITHUB_BASE_REF")
+		if len(baseBranch) == 0 {
+			logger.Error("ABORTING: the code owners of the PR cannot be determined: " +
+				"its base branch is unknown (GITHUB_BASE_REF is empty)")
+			exitWith(ExitInvalidArgs)
+		}
+		rules, err := readCodeowners(pathToRepo, "origin/"+baseBranch)
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitInvalidArgs)
+		}
+		changedFiles, err := gitDiffFiles(pathToRepo, "origin/"+baseBranch)
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitFailure)
+		}
+		owners := codeOwners(rules, changedFiles)
+		logger.Info(fmt.Sprintf("Code owners of the files changed by the PR: %s", strings.Join(owners, ", ")))
+		requiredApprovers = mergeApprovers(requiredApprovers, owners)
+	}
+
+	// require the reviewers of the PR as well, requested or having reviewed it (if enabled)
+	if getEnvBool("ACTION_AUTO_REVIEWERS") {
+		githubAPIOptions, err := newGitHubOptions(newHTTPClient(nil))
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitInvalidArgs)
+		}
+		prNumber, err := gitHubPullRequestNumber()
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitInvalidArgs)
+		}
+		reviewers, err := fetchPRReviewers(githubAPIOptions, prNumber)
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: error getting the reviewers of PR #%d: %v", prNumber, err))
+			exitWith(ExitAPIError)
+		}
+		logger.Info(fmt.Sprintf("Reviewers of PR #%d: %s", prNumber, strings.Join(reviewers, ", ")))
+		requiredApprovers = mergeApprovers(requiredApprovers, reviewers)
+	}
+
+	// replace the GitHub teams (<org>/<team>) with their members
+	requiredApprovers, err = expandTeams(requiredApprovers, func() (*githubOptions, error) {
+		return newGitHubOptions(newHTTPClient(nil))
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: %v", err))
+		exitWith(ExitAPIError)
+	}
+
+	// the CNIL REST API server URL defaults to the CNIL host with the REST API port
+	cnilServerURL := strings.TrimSuffix(strings.TrimSpace(os.Getenv("ACTION_CNIL_URL")), "/")
+	if len(cnilServerURL) == 0 {
+		cnilServerURL = fmt.Sprintf("https://%s:%s", cnilHost, cnilRESTPort)
+	}
+	cnilRESTURL := cnilServerURL + "/api/v1"
+
+	ledgerIDFromRepo := getEnvBool("LEDGER_ID_FROM_REPO")
+	spiffeEndpointSocket := strings.TrimSpace(os.Getenv("SPIFFE_ENDPOINT_SOCKET"))
+	signerLookupURL := strings.TrimSpace(os.Getenv("SIGNER_LOOKUP_URL"))
+
+	// make sure the signer IDs follow the organization identity format (if specified)
+	if signerIDRegex := strings.TrimSpace(os.Getenv("SIGNER_ID_REGEX")); len(signerIDRegex) > 0 {
+		pattern, err := regexp.Compile(signerIDRegex)
+		if err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: error parsing SIGNER_ID_REGEX value %s: %v", signerIDRegex, err))
+			exitWith(ExitInvalidArgs)
+		}
+		approvers := configuredApprovers(approver, requiredApprovers, cnilAPIKeysStr)
+		if invalid := invalidSignerIDs(pattern, approvers); len(invalid) > 0 {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: the signer ID of the following approver(s) does not match SIGNER_ID_REGEX %s: %s",
+				signerIDRegex, strings.Join(invalid, ", ")))
+			exitWith(ExitInvalidArgs)
+		}
+	}
+
+	// read the API keys of the required approvers from the OS keychain (if enabled), falling
+	// back to the API keys argument or the key rotation if any of them is not found
+	if getEnvBool("CNIL_USE_KEYCHAIN") && len(cnilAPIKeysStr) == 0 && len(requiredApprovers) > 0 {
+		var keychainAPIKeys []string
+		for _, requiredApprover := range splitRequiredApprovers(requiredApprovers) {
+			apiKey, err := keychainAPIKey(requiredApprover + identitySuffix)
+			if err != nil {
+				logger.Warn(fmt.Sprintf("WARNING: %v, ignoring the OS keychain", err))
+				keychainAPIKeys = nil
+				break
+			}
+			keychainAPIKeys = append(keychainAPIKeys, apiKey)
+		}
+		cnilAPIKeysStr = strings.Join(keychainAPIKeys, ",")
+	}
+
+	// only use externally managed API keys (if enabled): the API keys argument, the OS
+	// keychain or the signer lookup service
+	if getEnvBool("DISABLE_KEY_ROTATION") && len(cnilAPIKeysStr) == 0 && len(signerLookupURL) == 0 {
+		logger.Error("ABORTING: DISABLE_KEY_ROTATION is enabled, but no API key has been specified: " +
+			"the API keys must be specified as argument, read from the OS keychain (CNIL_USE_KEYCHAIN) " +
+			"or looked up with SIGNER_LOOKUP_URL.")
+		exitWith(ExitInvalidArgs)
+	}
+
+	// the Sigstore bundles are only trusted if they are signed by the expected identity
+	if len(strings.TrimSpace(os.Getenv("SIGSTORE_BUNDLE_PATH"))) > 0 &&
+		(len(strings.TrimSpace(os.Getenv("SIGSTORE_CERTIFICATE_OIDC_ISSUER"))) == 0 ||
+			len(strings.TrimSpace(os.Getenv("SIGSTORE_CERTIFICATE_IDENTITY_REGEXP"))) == 0) {
+		logger.Error("ABORTING: SIGSTORE_BUNDLE_PATH is set, but SIGSTORE_CERTIFICATE_OIDC_ISSUER and " +
+			"SIGSTORE_CERTIFICATE_IDENTITY_REGEXP, which pin the identity of the bundle certificate, are not.")
+		exitWith(ExitInvalidArgs)
+	}
+
+	// the GitHub Attestations API only accepts Sigstore bundles, which the action cannot sign
+	if createAttestation && len(strings.TrimSpace(os.Getenv("SIGSTORE_BUNDLE_PATH"))) == 0 {
+		logger.Error("ABORTING: CREATE_GITHUB_ATTESTATION is enabled, but no Sigstore bundle of the PR " +
+			"has been specified with SIGSTORE_BUNDLE_PATH.")
+		exitWith(ExitInvalidArgs)
+	}
 
 	var emptyRequiredArgs []string
 	if len(cnilAPIKeysStr) == 0 {
-		if len(cnilToken) == 0 {
+		if len(cnilToken) == 0 && len(spiffeEndpointSocket) == 0 && len(signerLookupURL) == 0 {
 			emptyRequiredArgs = append(emptyRequiredArgs, "CNIL REST API personal token")
 		}
-		if len(cnilLedgerID) == 0 {
+		if len(cnilLedgerID) == 0 && !ledgerIDFromRepo && len(signerLookupURL) == 0 {
 			emptyRequiredArgs = append(emptyRequiredArgs, "CNIL ledger ID")
 		}
 		if len(requiredApprovers) == 0 {
@@ -83,163 +624,1401 @@ func main() {
 		}
 	}
 	if len(emptyRequiredArgs) > 0 {
-		fmt.Printf(red, fmt.Sprintf(
+		logger.Error(fmt.Sprintf(
 			"ABORTING: no API key has been specified, but the following argument(s) are also unspecified: \n   %s\n"+
 				"These arguments are required to create/rotate API key(s) for the required PR approver(s).",
 			strings.Join(emptyRequiredArgs, ", ")))
-		os.Exit(1)
+		exitWith(ExitInvalidArgs)
 	}
 
 	noTLS, err := strconv.ParseBool(cnilNoTLS)
 	if err != nil {
-		fmt.Printf(red, fmt.Sprintf(
-			"ABORTING: error parsing the \"no TLS\" argument value \"%s\": %v\n",
+		logger.Error(fmt.Sprintf(
+			"ABORTING: error parsing the \"no TLS\" argument value \"%s\": %v",
 			cnilNoTLS, err))
-		os.Exit(1)
+		exitWith(ExitInvalidArgs)
+	}
+
+	// rely on the mTLS of the service mesh for the gRPC connection (if enabled)
+	useServiceMesh := getEnvBool("USE_SERVICE_MESH")
+	if useServiceMesh {
+		noTLS = true
+		logger.Warn("WARNING: USE_SERVICE_MESH is enabled: the CNIL gRPC connection is NOT encrypted " +
+			"by the action and relies on the service mesh (e.g. Istio, Linkerd) mTLS for its integrity " +
+			"and confidentiality. Only use it in trusted mesh environments.")
+	}
+
+	// notarize the PR as untrusted or unsupported instead of trusted (if specified), e.g. to
+	// veto it
+	notarizationStatus := vcnMeta.StatusTrusted
+	switch status := strings.ToLower(strings.TrimSpace(os.Getenv("ACTION_NOTARIZATION_STATUS"))); status {
+	case "", "trusted":
+	case "untrusted":
+		notarizationStatus = vcnMeta.StatusUntrusted
+	case "unsupported":
+		notarizationStatus = vcnMeta.StatusUnsupported
+	default:
+		logger.Error(fmt.Sprintf(
+			"ABORTING: invalid ACTION_NOTARIZATION_STATUS value \"%s\": expected trusted, untrusted or unsupported",
+			status))
+		exitWith(ExitInvalidArgs)
+	}
+
+	ifAlreadySigned := strings.ToLower(strings.TrimSpace(os.Getenv("NOTARIZE_IF_ALREADY_SIGNED")))
+	switch ifAlreadySigned {
+	case "":
+		ifAlreadySigned = alreadySignedSkip
+	case alreadySignedSkip, alreadySignedFail, alreadySignedResign:
+	default:
+		logger.Error(fmt.Sprintf(
+			"ABORTING: invalid NOTARIZE_IF_ALREADY_SIGNED value \"%s\": expected one of %s, %s, %s",
+			ifAlreadySigned, alreadySignedSkip, alreadySignedFail, alreadySignedResign))
+		exitWith(ExitInvalidArgs)
+	}
+	// the notarization of the current approver is only made in the current run if it is
+	// made again
+	if getEnvBool("VERIFY_GITHUB_RUN_ID") && ifAlreadySigned != alreadySignedResign {
+		logger.Warn(fmt.Sprintf(
+			"WARNING: VERIFY_GITHUB_RUN_ID requires NOTARIZE_IF_ALREADY_SIGNED=%s, otherwise the "+
+				"notarizations of previous runs are not made again and are not counted", alreadySignedResign))
+	}
+
+	tlsMinVersion, err := parseTLSMinVersion(strings.TrimSpace(os.Getenv("CNIL_TLS_MIN_VERSION")))
+	if err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: %v", err))
+		exitWith(ExitInvalidArgs)
+	}
+	tlsConfig := &tls.Config{MinVersion: tlsMinVersion}
+
+	grpcMaxRecvMsgSize := getEnvUint("GRPC_MAX_RECV_MSG_SIZE", defaultGRPCMsgSize)
+	grpcMaxSendMsgSize := getEnvUint("GRPC_MAX_SEND_MSG_SIZE", defaultGRPCMsgSize)
+	if grpcMaxRecvMsgSize > maxGRPCMsgSize || grpcMaxSendMsgSize > maxGRPCMsgSize {
+		logger.Error(fmt.Sprintf(
+			"ABORTING: GRPC_MAX_RECV_MSG_SIZE (%d) and GRPC_MAX_SEND_MSG_SIZE (%d) must not exceed %d bytes",
+			grpcMaxRecvMsgSize, grpcMaxSendMsgSize, maxGRPCMsgSize))
+		exitWith(ExitInvalidArgs)
+	}
+
+	cnilRetryPolicy, err := newRetryPolicyFromEnv()
+	if err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: %v", err))
+		exitWith(ExitInvalidArgs)
+	}
+
+	// make sure the GitHub token has the permissions needed by the enabled features
+	// before making any CNIL call (if enabled)
+	if getEnvBool("VERIFY_WORKFLOW_PERMISSIONS") && os.Getenv("GITHUB_ACTIONS") == "true" {
+		var permissions []string
+		if getEnvBool("REQUIRE_CI_SUCCESS") {
+			permissions = append(permissions, "actions", "checks")
+		}
+		if len(strings.TrimSpace(os.Getenv("GITHUB_ENVIRONMENT"))) > 0 {
+			permissions = append(permissions, "deployments")
+		}
+		if getEnvBool("REQUEST_MISSING_REVIEWS") {
+			permissions = append(permissions, "pull-requests")
+		}
+		if commitStatus {
+			permissions = append(permissions, "statuses")
+		}
+		if getEnvBool("ACTION_GITHUB_CHECK_RUN") && !getEnvBool("REQUIRE_CI_SUCCESS") {
+			permissions = append(permissions, "checks")
+		}
+		githubAPIOptions, err := newGitHubOptions(newHTTPClient(tlsConfig))
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitInvalidArgs)
+		}
+		sha, err := gitHeadCommit(pathToRepo)
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitFailure)
+		}
+		if err := checkGitHubPermissions(githubAPIOptions, sha, permissions); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitAPIError)
+		}
+	}
+
+	cnilOrgID := strings.TrimSpace(os.Getenv("CNIL_ORG_ID"))
+	cnilServerVersion, err := checkCNILServerVersion(
+		newCNILHTTPClient(tlsConfig, cnilOrgID), cnilServerURL)
+	var statusErr *unexpectedStatusError
+	switch {
+	case errors.As(err, &statusErr):
+		// the version endpoint is not exposed by all CNIL deployments
+		logger.Warn(fmt.Sprintf("WARNING: error getting the CNIL server version: %v", err))
+	case err != nil:
+		logger.Error(fmt.Sprintf("ABORTING: %v", err))
+		exitWith(ExitAPIError)
+	default:
+		logger.Info(fmt.Sprintf("CNIL server version: %s", cnilServerVersion))
+	}
+
+	// reject the notarizations timestamped in the future of the CNIL server time, beyond
+	// the clock skew of the signing clients
+	maxClockSkew = getEnvDuration("MAX_CLOCK_SKEW", defaultMaxClockSkew)
+	if maxClockSkew < 0 {
+		logger.Error(fmt.Sprintf("ABORTING: MAX_CLOCK_SKEW (%s) must not be negative", maxClockSkew))
+		exitWith(ExitInvalidArgs)
+	}
+	if !cnilMockMode {
+		serverTime, err := cnilServerTime(newCNILHTTPClient(tlsConfig, cnilOrgID), cnilServerURL)
+		if err != nil {
+			logger.Warn(fmt.Sprintf(
+				"WARNING: error getting the CNIL server time, using the local time instead: %v", err))
+		} else {
+			cnilClockOffset = time.Until(serverTime)
+		}
+	}
+	logger.Info(fmt.Sprintf("vcn library version: %s", vcnLibraryVersion()))
+
+	// make sure the action is compiled with an up-to-date vcn library (if enabled)
+	if getEnvBool("FAIL_IF_VCN_LIBRARY_OUTDATED") {
+		if err := checkVCNLibraryOutdated(
+			newHTTPClient(tlsConfig), strings.TrimSpace(os.Getenv("GITHUB_TOKEN"))); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitFailure)
+		}
+	}
+
+	var apiKeyScope json.RawMessage
+	if apiKeyScopeStr := strings.TrimSpace(os.Getenv("API_KEY_SCOPE")); len(apiKeyScopeStr) > 0 {
+		var scope map[string]interface{}
+		if err := json.Unmarshal([]byte(apiKeyScopeStr), &scope); err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: error parsing API_KEY_SCOPE value %s: expected a JSON object: %v",
+				apiKeyScopeStr, err))
+			exitWith(ExitInvalidArgs)
+		}
+		apiKeyScope = json.RawMessage(apiKeyScopeStr)
+	}
+
+	artifactLabel := strings.TrimSpace(os.Getenv("ARTIFACT_LABEL"))
+	if err := validateArtifactLabel(artifactLabel); err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: %v", err))
+		exitWith(ExitInvalidArgs)
+	}
+
+	metadataPerApprover, err := parsePerApproverMetadata(
+		strings.TrimSpace(os.Getenv("PER_APPROVER_METADATA")))
+	if err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: %v", err))
+		exitWith(ExitInvalidArgs)
+	}
+
+	excusedApprovers, err := parseExcusedApprovers(
+		strings.TrimSpace(os.Getenv("EXCUSE_APPROVERS")),
+		strings.TrimSpace(os.Getenv("EXCUSE_EXPIRES_AT")),
+		time.Now())
+	if err != nil {
+		logger.Error(fmt.Sprintf("ABORTING: %v", err))
+		exitWith(ExitInvalidArgs)
+	}
+
+	// skip the PR if it does not change any file compared to its base branch (if enabled)
+	if getEnvBool("SKIP_ON_NO_DIFF") {
+		baseBranch := os.Getenv("GITHUB_BASE_REF")
+		if len(baseBranch) == 0 {
+			logger.Warn("WARNING: SKIP_ON_NO_DIFF is ignored: the base branch of the PR is unknown " +
+				"(GITHUB_BASE_REF is empty)")
+		} else if hasDiff, err := gitHasDiff(pathToRepo, "origin/"+baseBranch); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitFailure)
+		} else if !hasDiff {
+			logSuccess(fmt.Sprintf(
+				"SKIPPING notarization and verification: the PR does not change any file compared to "+
+					"its base branch %s", baseBranch))
+			return
+		}
+	}
+
+	// fingerprint the action inputs, secrets excluded (if enabled)
+	var inputsHash string
+	fingerprintInputs := getEnvBool("FINGERPRINT_WORKFLOW_INPUTS")
+	if fingerprintInputs {
+		inputsHash = workflowInputsHash(map[string]string{
+			"cnil_host":               cnilHost,
+			"cnil_grpc_port":          cnilgRPCPort,
+			"cnil_grpc_no_tls":        cnilNoTLS,
+			"cnil_rest_port":          cnilRESTPort,
+			"cnil_ledger_id":          cnilLedgerID,
+			"required_approvers":      requiredApprovers,
+			"cnil_org_id":             cnilOrgID,
+			"notarize_if_signed":      ifAlreadySigned,
+			"per_approver_metadata":   strings.TrimSpace(os.Getenv("PER_APPROVER_METADATA")),
+			"api_key_scope":           string(apiKeyScope),
+			"ledger_id_from_repo":     strconv.FormatBool(ledgerIDFromRepo),
+			"verify_github_run_id":    strconv.FormatBool(getEnvBool("VERIFY_GITHUB_RUN_ID")),
+			"require_ci_success":      strconv.FormatBool(getEnvBool("REQUIRE_CI_SUCCESS")),
+			"excuse_approvers":        strings.TrimSpace(os.Getenv("EXCUSE_APPROVERS")),
+			"excuse_expires_at":       strings.TrimSpace(os.Getenv("EXCUSE_EXPIRES_AT")),
+			"require_clean_workspace": strconv.FormatBool(getEnvBool("REQUIRE_CLEAN_WORKSPACE")),
+			"min_approvals":           strconv.FormatUint(minApprovals, 10),
+			"required_approver_order": strings.TrimSpace(os.Getenv("REQUIRED_APPROVER_ORDER")),
+			"notarization_status":     notarizationStatus.String(),
+			"max_notarization_age":    getEnvDuration("ACTION_MAX_NOTARIZATION_AGE", 0).String(),
+		})
+		logger.Info(fmt.Sprintf("Action inputs fingerprint: %s", inputsHash))
+	}
+
+	// make sure the required approvers are still members of the GitHub organization (if specified)
+	if githubOrg := strings.TrimSpace(os.Getenv("GITHUB_ORG")); len(githubOrg) > 0 {
+		githubAPIOptions, err := newGitHubOptions(newHTTPClient(tlsConfig))
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitInvalidArgs)
+		}
+		nonMembers, err := nonOrgMembers(githubAPIOptions, githubOrg, configuredApprovers("", requiredApprovers, cnilAPIKeysStr))
+		if err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: error checking the members of GitHub organization %s: %v", githubOrg, err))
+			exitWith(ExitAPIError)
+		}
+		if len(nonMembers) > 0 {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: the following required approver(s) are not members of GitHub organization %s: %s",
+				githubOrg, strings.Join(nonMembers, ", ")))
+			exitWith(ExitInvalidArgs)
+		}
+	}
+
+	// fail fast if the CNIL APIs are unreachable (unless skipped)
+	if getEnvBool("ACTION_SKIP_PREFLIGHT") {
+		logger.Debug("Skipping the CNIL connectivity pre-flight check")
+	} else {
+		var preflightCNILOptions *cnilOptions
+		if len(cnilAPIKeysStr) == 0 && len(signerLookupURL) == 0 {
+			// the REST API is only used to manage the API keys
+			preflightCNILOptions = &cnilOptions{
+				baseURL:     cnilRESTURL,
+				httpClient:  newCNILHTTPClient(tlsConfig, cnilOrgID),
+				retryPolicy: cnilRetryPolicy,
+			}
+		}
+		if err := preflightCheck(preflightCNILOptions, &vcnOptions{
+			cnilHost:  cnilHost,
+			cnilPort:  cnilgRPCPort,
+			noTLS:     noTLS,
+			tlsConfig: tlsConfig,
+
+			grpcMaxRecvMsgSize: int(grpcMaxRecvMsgSize),
+			grpcMaxSendMsgSize: int(grpcMaxSendMsgSize),
+		}); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: connectivity pre-flight check failed: %v", err))
+			exitWith(ExitAPIError)
+		}
 	}
 
 	// get and rotate or create API keys for each required approver
 	apiKeyPerRequiredApprover := make(map[string]string)
-	if len(cnilAPIKeysStr) == 0 {
-		cnilAPIOptions := &cnilOptions{
-			baseURL:  cnilRESTURL,
-			token:    cnilToken,
-			ledgerID: cnilLedgerID,
+	ledgerID := cnilLedgerID
+	var cnilAPIOptions *cnilOptions
+	var pendingAPIKeys <-chan approverAPIKey
+	var nbPendingAPIKeys int
+	if len(cnilAPIKeysStr) == 0 && len(signerLookupURL) > 0 {
+		// the API keys are managed by an external service
+		signerLookupToken := strings.TrimSpace(os.Getenv("SIGNER_LOOKUP_TOKEN"))
+		for _, requiredApprover := range splitRequiredApprovers(requiredApprovers) {
+			apiKey, err := lookupExternalSignerKey(requiredApprover, signerLookupURL, signerLookupToken)
+			if err != nil {
+				logger.Error(fmt.Sprintf(
+					"ABORTING: error looking up the API key of required approver %s: %v",
+					requiredApprover, err))
+				exitWith(ExitAPIError)
+			}
+			apiKeyPerRequiredApprover[requiredApprover] = apiKey
+		}
+	} else if len(cnilAPIKeysStr) == 0 {
+		// spread the key rotations of concurrent pipelines over time (if enabled)
+		if jitterMs := getEnvUint("KEY_ROTATION_JITTER_MS", 0); jitterMs > 0 {
+			jitter, err := randomDuration(time.Duration(jitterMs) * time.Millisecond)
+			if err != nil {
+				logger.Error(fmt.Sprintf("ABORTING: %v", err))
+				exitWith(ExitFailure)
+			}
+			logger.Info(fmt.Sprintf("Waiting %s before rotating the API keys ...", jitter))
+			time.Sleep(jitter)
+		}
+
+		// authenticate to the CNIL REST API using the workload x509-SVID (if available)
+		restTLSConfig := tlsConfig
+		if len(spiffeEndpointSocket) > 0 {
+			svid, err := fetchX509SVID(spiffeEndpointSocket)
+			if err != nil {
+				logger.Error(fmt.Sprintf("ABORTING: %v", err))
+				exitWith(ExitAPIError)
+			}
+			restTLSConfig = tlsConfig.Clone()
+			restTLSConfig.Certificates = []tls.Certificate{*svid}
+		}
+		cnilAPIOptions = &cnilOptions{
+			baseURL:     cnilRESTURL,
+			token:       cnilToken,
+			ledgerID:    cnilLedgerID,
+			apiKeyScope: apiKeyScope,
+			orgID:       cnilOrgID,
+			retryPolicy: cnilRetryPolicy,
+			ledger:      cnilLedger,
+
+			archiveOldKeys: getEnvBool("ARCHIVE_OLD_KEYS"),
+			keyRotations:   newKeyRotationCounter(),
+		}
+		cnilAPIOptions.httpClient = newCNILHTTPClient(restTLSConfig, cnilAPIOptions.orgID)
+		if len(cnilAPIOptions.ledgerID) == 0 {
+			repository := os.Getenv("GITHUB_REPOSITORY")
+			ledgerID, err = getLedgerIDByName(cnilAPIOptions, repository)
+			if err != nil {
+				logger.Error(fmt.Sprintf(
+					"ABORTING: error deriving the CNIL ledger ID from repository \"%s\": %v",
+					repository, err))
+				exitWith(ExitAPIError)
+			}
+			logger.Info(fmt.Sprintf("Using CNIL ledger %s derived from repository %s", ledgerID, repository))
+			cnilAPIOptions.ledgerID = ledgerID
 		}
-		if err := getAndRotateOrCreateAPIKeys(
+		// make sure the ledger has not been rolled back before using it (if enabled)
+		if len(baselineLedgerStateFile) > 0 && cnilMockMode {
+			logger.Warn("WARNING: the ledger rollback check is skipped in CNIL mock mode")
+		} else if len(baselineLedgerStateFile) > 0 {
+			baselineState, err := readLedgerState(baselineLedgerStateFile)
+			if err != nil {
+				logger.Error(fmt.Sprintf("ABORTING: %v", err))
+				exitWith(ExitInvalidArgs)
+			}
+			currentState, err := fetchLedgerState(cnilAPIOptions)
+			if err != nil {
+				logger.Error(fmt.Sprintf("ABORTING: %v", err))
+				exitWith(ExitAPIError)
+			}
+			if err := checkLedgerRollback(baselineState, currentState, cnilAPIOptions.ledgerID); err != nil {
+				logger.Error(fmt.Sprintf("ABORTING: %v", err))
+				exitWith(ExitVerificationError)
+			}
+			logger.Info(fmt.Sprintf("Ledger %s block height: %d (baseline: %d)",
+				cnilAPIOptions.ledgerID, currentState.BlockHeight, baselineState.BlockHeight))
+		}
+		if getEnvBool("PARALLEL_KEY_AND_VERIFY") {
+			// only wait for the key of the current approver, the other ones are verified
+			// while being rotated
+			requiredApproversArr := splitRequiredApprovers(requiredApprovers)
+			pendingAPIKeys = rotateAPIKeysPipelined(cnilAPIOptions, requiredApproversArr, approver)
+			nbPendingAPIKeys = len(requiredApproversArr)
+			for _, requiredApprover := range requiredApproversArr {
+				if requiredApprover != approver {
+					continue
+				}
+				if err := receiveAPIKeys(pendingAPIKeys, 1, apiKeyPerRequiredApprover); err != nil {
+					logger.Error(fmt.Sprintf("ABORTING: %v", err))
+					exitWith(ExitAPIError)
+				}
+				nbPendingAPIKeys--
+			}
+		} else if err := getAndRotateOrCreateAPIKeys(
 			cnilAPIOptions,
 			requiredApprovers,
 			apiKeyPerRequiredApprover,
 		); err != nil {
-			fmt.Printf(red, fmt.Sprintf("ABORTING: %v\n", err))
-			os.Exit(1)
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitAPIError)
 		}
 	} else {
+		if len(baselineLedgerStateFile) > 0 {
+			logger.Error("ABORTING: BASELINE_LEDGER_STATE_FILE requires the CNIL REST API personal token " +
+				"and ledger ID instead of API keys")
+			exitWith(ExitInvalidArgs)
+		}
 		var requiredApproversArr []string
 		cnilAPIKeys := strings.Split(cnilAPIKeysStr, ",")
 		for _, ak := range cnilAPIKeys {
 			pieces := strings.Split(ak, ".")
 			if len(pieces) < 2 {
-				fmt.Printf(red,
-					"the specified API key is not supported: must be of the form <identity>.<secret>")
-				os.Exit(1)
+				logger.Error("the specified API key is not supported: must be of the form <identity>.<secret>")
+				exitWith(ExitInvalidArgs)
 			}
 			signerID := strings.TrimSuffix(strings.Join(pieces[:len(pieces)-1], "."), identitySuffix)
 			if _, ok := apiKeyPerRequiredApprover[signerID]; ok {
-				fmt.Printf(red, fmt.Sprintf(
+				logger.Error(fmt.Sprintf(
 					"more than one API key has been specified for the same signer ID \"%s\"", signerID))
-				os.Exit(1)
+				exitWith(ExitInvalidArgs)
 			}
 			apiKeyPerRequiredApprover[signerID] = ak
 			requiredApproversArr = append(requiredApproversArr, signerID)
 		}
-		requiredApprovers = strings.Join(requiredApproversArr, ", ")
+		requiredApprovers = strings.Join(requiredApproversArr, ", ")
+	}
+
+	if nbApprovers := len(apiKeyPerRequiredApprover) + nbPendingAPIKeys; minApprovals > uint64(nbApprovers) {
+		logger.Error(fmt.Sprintf(
+			"ABORTING: the minimum number of approvals (%d) exceeds the number of required approvers (%d)",
+			minApprovals, nbApprovers))
+		exitWith(ExitInvalidArgs)
+	}
+
+	if outputApproverKeys {
+		if err := receiveAPIKeys(pendingAPIKeys, nbPendingAPIKeys, apiKeyPerRequiredApprover); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitAPIError)
+		}
+		pendingAPIKeys, nbPendingAPIKeys = nil, 0
+		printApproverKeys(apiKeyPerRequiredApprover)
+	}
+
+	// fingerprint the list of required approvers, to detect changes between notarizations
+	approversHash := requiredApproversHash(requiredApprovers)
+
+	// make sure the git repository is not unexpectedly large (if a limit is specified)
+	if maxSizeMB := getEnvUint("MAX_ARTIFACT_SIZE_MB", 0); maxSizeMB > 0 {
+		repoSizeKiB, err := gitRepoSizeKiB(pathToRepo)
+		if err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: error computing the size of git repo %s: %v", pathToRepo, err))
+			exitWith(ExitFailure)
+		}
+		if repoSizeKiB > maxSizeMB*1024 {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: git repo %s size is %.2f MB, which exceeds the maximum of %d MB",
+				pathToRepo, float64(repoSizeKiB)/1024, maxSizeMB))
+			exitWith(ExitVerificationError)
+		}
+	}
+
+	// make sure the workspace does not contain uncommitted changes (if required)
+	if getEnvBool("REQUIRE_CLEAN_WORKSPACE") {
+		changes, err := gitUncommittedChanges(pathToRepo)
+		if err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: error checking the status of git repo %s: %v", pathToRepo, err))
+			exitWith(ExitFailure)
+		}
+		if len(changes) > 0 {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: git repo %s contains uncommitted changes or untracked files:\n   %s",
+				pathToRepo, strings.Join(changes, "\n   ")))
+			exitWith(ExitVerificationError)
+		}
+	}
+
+	// make sure the local VCN store directory exists
+	options := &vcnOptions{
+		storeDir:  vcnStoreDir,
+		cnilHost:  cnilHost,
+		cnilPort:  cnilgRPCPort,
+		noTLS:     noTLS,
+		tlsConfig: tlsConfig,
+
+		grpcMaxRecvMsgSize: int(grpcMaxRecvMsgSize),
+		grpcMaxSendMsgSize: int(grpcMaxSendMsgSize),
+		ledger:             cnilLedger,
+
+		dryRun:             dryRun,
+		maxNotarizationAge: getEnvDuration("ACTION_MAX_NOTARIZATION_AGE", 0),
+		notarizationStatus: notarizationStatus,
+	}
+	if caBundleFile := strings.TrimSpace(os.Getenv("SIGNER_CA_BUNDLE_FILE")); len(caBundleFile) > 0 {
+		if options.signerCAPool, err = loadCertPool(caBundleFile); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitInvalidArgs)
+		}
+	}
+	if err := os.MkdirAll(options.storeDir, os.ModePerm); err != nil {
+		logger.Error(fmt.Sprintf(
+			"error creating VCN local store directory %s: %v", options.storeDir, err))
+	}
+	// initialize VCN store
+	vcnStore.SetDir(options.storeDir)
+	vcnStore.LoadConfig()
+
+	// make sure CNIL is reachable with a side-effect free call (if a test artifact is specified)
+	if testArtifactHash := strings.TrimSpace(os.Getenv("CNIL_TEST_ARTIFACT_HASH")); len(testArtifactHash) > 0 {
+		if err := receiveAPIKeys(pendingAPIKeys, nbPendingAPIKeys, apiKeyPerRequiredApprover); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitAPIError)
+		}
+		pendingAPIKeys, nbPendingAPIKeys = nil, 0
+		for _, apiKey := range apiKeyPerRequiredApprover {
+			options.cnilAPIKey = apiKey
+			break
+		}
+		if err := pingCNIL(options, testArtifactHash); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: CNIL is unreachable: %v", err))
+			exitWith(ExitAPIError)
+		}
+		logSuccess(fmt.Sprintf("Successfully connected to CNIL %s:%s", cnilHost, cnilgRPCPort))
+	}
+
+	// verify that the CI images used by the repository workflows are notarized (if enabled)
+	if getEnvBool("VERIFY_CI_IMAGES") {
+		// the keys of all required approvers are needed
+		if err := receiveAPIKeys(pendingAPIKeys, nbPendingAPIKeys, apiKeyPerRequiredApprover); err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitAPIError)
+		}
+		pendingAPIKeys, nbPendingAPIKeys = nil, 0
+
+		logger.Info("\nVerifying CI images used by the workflow ...")
+		imageRefs, err := ciImageRefs(pathToRepo)
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitFailure)
+		}
+		unverifiedImages, err := verifyCIImages(imageRefs, apiKeyPerRequiredApprover, options)
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitVerificationError)
+		}
+		if len(unverifiedImages) > 0 {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: the following CI image(s) are not notarized:\n   - %s",
+				strings.Join(unverifiedImages, "\n   - ")))
+			exitWith(ExitVerificationError)
+		}
+		logSuccess(fmt.Sprintf("All %d CI image(s) are notarized", len(imageRefs)))
+	}
+
+	triggerWebhooks := getEnvBool("LEDGER_WEBHOOK")
+	if triggerWebhooks && cnilAPIOptions == nil {
+		logger.Error("ABORTING: LEDGER_WEBHOOK requires the CNIL REST API personal token " +
+			"and ledger ID instead of API keys")
+		exitWith(ExitInvalidArgs)
+	}
+
+	// the API keys are only rotated once for all the artifacts, and the artifacts are only
+	// reported once their results are merged
+	var results []*notarizationResult
+	// the hashes of the artifacts notarized in this run
+	var notarizedArtifactHashes []string
+	// the overall outcome is the one of the first failing artifact (if any)
+	var failureExitCode ExitCode
+	workflowNotarized := false
+	// the commit of the base branch, once notarized (only once for all the artifacts)
+	notarizedBaseCommit := ""
+	for _, artifactPath := range artifactPaths {
+		if len(artifactPaths) > 1 {
+			description := artifactPath
+			if len(description) == 0 {
+				description = "git repository of the PR"
+			}
+			logger.Info(fmt.Sprintf("\nNotarizing and verifying artifact %s ...", description))
+		}
+		var result *notarizationResult
+		exitCode := runAction(func() {
+			// create VCN artifact from the git repository folder
+			var artifact *vcnAPI.Artifact
+			if len(artifactPath) > 0 {
+				// notarize a build output instead of the git repository
+				artifact, err = vcnArtifactFromPath(artifactPath)
+				if err != nil {
+					logger.Error(fmt.Sprintf(
+						"ABORTING: error creating VCN artifact from path %s: %v", artifactPath, err))
+					exitWith(ExitFailure)
+				}
+			} else {
+				artifact, err = vcnArtifactFromGitRepo(pathToRepo)
+				if err != nil {
+					logger.Error(fmt.Sprintf(
+						"ABORTING: error creating VCN artifact from git repo %s: %v", pathToRepo, err))
+					exitWith(ExitFailure)
+				}
+			}
+			if len(artifactLabel) > 0 {
+				artifact.Name = artifactLabel
+			}
+
+			// notarize the git repository artifact for the current PR approver (if required)
+			if notarizationKey, ok := apiKeyPerRequiredApprover[approver]; ok {
+				options.cnilAPIKey = notarizationKey
+
+				// make sure the CI checks of the PR commit have succeeded (if required)
+				if getEnvBool("REQUIRE_CI_SUCCESS") {
+					logger.Info("\nVerifying if the CI checks of the PR commit have succeeded ...")
+					githubAPIOptions, err := newGitHubOptions(newHTTPClient(tlsConfig))
+					if err != nil {
+						logger.Error(fmt.Sprintf("ABORTING: %v", err))
+						exitWith(ExitInvalidArgs)
+					}
+					headCommit, err := gitHeadCommit(pathToRepo)
+					if err != nil {
+						logger.Error(fmt.Sprintf("ABORTING: %v", err))
+						exitWith(ExitFailure)
+					}
+					unsuccessfulChecks, err := unsuccessfulCheckSuites(githubAPIOptions, headCommit)
+					if err != nil {
+						logger.Error(fmt.Sprintf(
+							"ABORTING: error getting the CI checks of commit %s: %v", headCommit, err))
+						exitWith(ExitAPIError)
+					}
+					if len(unsuccessfulChecks) > 0 {
+						logger.Error(fmt.Sprintf(
+							"ABORTING: the following CI checks of commit %s have not succeeded:\n   - %s",
+							headCommit, strings.Join(unsuccessfulChecks, "\n   - ")))
+						exitWith(ExitVerificationError)
+					}
+				}
+
+				// check if the PR has already been notarized for the current approver
+				logger.Info("\nVerifying if the PR has already been notarized for the current approver ...")
+				existingCNILArtifact, err := verify(artifact, options)
+				if err != nil {
+					logger.Error(fmt.Sprintf(
+						"ABORTING: error verifying PR for current approver %s: %v", approver, err))
+					exitWith(ExitVerificationError)
+				}
+				notarizePR := true
+				if existingCNILArtifact != nil {
+					logger.Warn(fmt.Sprintf(`PR has already been notarized for current approver %s:
+      Hash:       %s
+      Timestamp:  %s
+      Status:     %s
+`,
+						approver,
+						existingCNILArtifact.Hash,
+						existingCNILArtifact.Timestamp.Format(time.RFC3339),
+						existingCNILArtifact.Status))
+					switch {
+					case existingCNILArtifact.Status != options.notarizationStatus:
+						// a change of status (e.g. a veto) must always be recorded
+						logger.Warn(fmt.Sprintf(
+							"Notarizing PR again: the status changes from %s to %s for current approver %s",
+							existingCNILArtifact.Status, options.notarizationStatus, approver))
+					case ifAlreadySigned == alreadySignedFail:
+						logger.Error("ABORTING: the PR must not be notarized more than once per approver")
+						exitWith(ExitNotarizationError)
+					case ifAlreadySigned == alreadySignedSkip:
+						notarizePR = false
+						logSuccess(fmt.Sprintf(
+							"SKIPPING notarization: PR is already notarized for current approver %s", approver))
+					}
+				}
+				if notarizePR && options.dryRun {
+					notarizePR = false
+					logger.Warn(fmt.Sprintf(
+						"SKIPPING notarization: dry run mode, the PR is only verified for current approver %s", approver))
+				}
+
+				if notarizePR && getEnvBool("NOTARIZE_BASE_BRANCH") && len(notarizedBaseCommit) == 0 {
+					baseBranch := os.Getenv("GITHUB_BASE_REF")
+					if len(baseBranch) == 0 {
+						logger.Error("ABORTING: the base branch of the PR is unknown (GITHUB_BASE_REF is empty)")
+						exitWith(ExitInvalidArgs)
+					}
+					logger.Info(fmt.Sprintf("\nNotarizing base branch %s ...", baseBranch))
+					baseArtifact, baseCommit, err := vcnArtifactFromGitCommit(pathToRepo, "origin/"+baseBranch)
+					if err != nil {
+						logger.Error(fmt.Sprintf(
+							"ABORTING: error creating VCN artifact from base branch %s: %v", baseBranch, err))
+						exitWith(ExitFailure)
+					}
+					// the base branch is trusted, whatever the status of the PR notarization
+					baseOptions := *options
+					baseOptions.notarizationStatus = vcnMeta.StatusTrusted
+					if err := notarize(baseArtifact, &baseOptions); err != nil {
+						logger.Error(fmt.Sprintf("ABORTING: base branch notarization error: %v", err))
+						exitWith(ExitNotarizationError)
+					}
+					notarizedBaseCommit = baseCommit
+					logSuccess(fmt.Sprintf(
+						"Successfully notarized base branch %s (%s) for current approver %s",
+						baseBranch, baseArtifact.Name, approver))
+				}
+				if notarizePR && len(notarizedBaseCommit) > 0 {
+					mergeMetadata(artifact, vcnAPI.Metadata{metadataBaseCommit: notarizedBaseCommit})
+				}
+
+				if notarizePR {
+					logger.Info("\nNotarizing PR ...")
+					mergeMetadata(artifact, gitHubRunMetadata())
+					mergeMetadata(artifact, vcnAPI.Metadata{metadataApproversHash: approversHash})
+					if certFile := strings.TrimSpace(os.Getenv("SIGNER_CERTIFICATE_FILE")); len(certFile) > 0 {
+						certPEM, err := ioutil.ReadFile(certFile)
+						if err != nil {
+							logger.Error(fmt.Sprintf(
+								"ABORTING: error reading signer certificate file %s: %v", certFile, err))
+							exitWith(ExitInvalidArgs)
+						}
+						mergeMetadata(artifact, vcnAPI.Metadata{metadataSignerCertificate: string(certPEM)})
+					}
+					if fingerprintInputs {
+						mergeMetadata(artifact, vcnAPI.Metadata{metadataInputsHash: inputsHash})
+					}
+					// pin the action configuration, to audit it after the approval (if enabled)
+					if getEnvBool("NOTARIZE_ACTION_CONFIG_HASH") {
+						if len(buildActionConfigHash) == 0 {
+							logger.Error("ABORTING: NOTARIZE_ACTION_CONFIG_HASH is enabled, but the action " +
+								"configuration hash has not been set at build time")
+							exitWith(ExitInvalidArgs)
+						}
+						mergeMetadata(artifact, vcnAPI.Metadata{metadataActionConfigHash: buildActionConfigHash})
+					}
+					// summarize the PR content for the ledger viewers (if enabled)
+					if includeGitLogSummary {
+						baseBranch := os.Getenv("GITHUB_BASE_REF")
+						if len(baseBranch) == 0 {
+							logger.Error("ABORTING: the commit summaries cannot be included, the base branch " +
+								"of the PR is unknown (GITHUB_BASE_REF is empty)")
+							exitWith(ExitInvalidArgs)
+						}
+						summaries, err := gitLogSummaries(
+							pathToRepo, "origin/"+baseBranch, getEnvUint("MAX_COMMIT_SUMMARIES", defaultMaxCommitSummaries))
+						if err != nil {
+							logger.Error(fmt.Sprintf("ABORTING: error listing the commits of the PR: %v", err))
+							exitWith(ExitFailure)
+						}
+						mergeMetadata(artifact, vcnAPI.Metadata{metadataCommitSummaries: truncateCommitSummaries(summaries)})
+					}
+					// record the dependencies approved with the PR (if enabled)
+					if notarizeLockfiles {
+						lockfileHashes := detectAndHashLockfiles(pathToRepo)
+						logger.Info(fmt.Sprintf("Found %d dependency lockfile(s) in the repository", len(lockfileHashes)))
+						mergeMetadata(artifact, vcnAPI.Metadata{metadataDependencyLockfiles: lockfileHashes})
+					}
+					mergeMetadata(artifact, metadataPerApprover[approver])
+					if getEnvBool("NOTARIZE_COMMENT_HASH") {
+						commentHash, err := approverReviewCommentsHash(newHTTPClient(tlsConfig), approver)
+						if err != nil {
+							logger.Error(fmt.Sprintf("ABORTING: error hashing the review comments of %s: %v", approver, err))
+							exitWith(ExitAPIError)
+						}
+						mergeMetadata(artifact, vcnAPI.Metadata{metadataReviewCommentHash: commentHash})
+					}
+					if hook := strings.TrimSpace(os.Getenv("PRE_NOTARIZE_HOOK")); len(hook) > 0 {
+						if err := runHook("pre-notarize hook", hook, map[string]string{"ARTIFACT_HASH": artifact.Hash}); err != nil {
+							logger.Error(fmt.Sprintf("ABORTING: %v", err))
+							exitWith(ExitNotarizationError)
+						}
+					}
+					notarizationErr := notarize(artifact, options)
+					if hook := strings.TrimSpace(os.Getenv("POST_NOTARIZE_HOOK")); len(hook) > 0 {
+						if err := runHook("post-notarize hook", hook, map[string]string{
+							"ARTIFACT_HASH":        artifact.Hash,
+							"NOTARIZATION_SUCCESS": strconv.FormatBool(notarizationErr == nil),
+						}); err != nil {
+							logger.Warn(fmt.Sprintf("WARNING: %v", err))
+						}
+					}
+					if notarizationErr != nil {
+						logger.Error(fmt.Sprintf("ABORTING: notarization error: %v", notarizationErr))
+						exitWith(ExitNotarizationError)
+					}
+					logSuccess(fmt.Sprintf(
+						"Successfully notarized PR for current approver %s", approver))
+					notarizedArtifactHashes = append(notarizedArtifactHashes, artifact.Hash)
+
+					// notify the systems consuming the ledger events right away (if enabled)
+					if triggerWebhooks {
+						if err := triggerLedgerWebhooks(cnilAPIOptions, artifact.Hash); err != nil {
+							logger.Warn(fmt.Sprintf("WARNING: error triggering the ledger webhooks: %v", err))
+						} else {
+							logSuccess("Successfully triggered the ledger webhooks")
+						}
+					}
+
+					// produce an in-toto link attestation for the review step as well (if enabled)
+					if signingKey := strings.TrimSpace(os.Getenv("IN_TOTO_SIGNING_KEY")); len(signingKey) > 0 {
+						signingKeyPEM := []byte(signingKey)
+						if !strings.HasPrefix(signingKey, "-----BEGIN") {
+							if signingKeyPEM, err = ioutil.ReadFile(signingKey); err != nil {
+								logger.Error(fmt.Sprintf(
+									"ABORTING: error reading in-toto signing key file %s: %v", signingKey, err))
+								exitWith(ExitInvalidArgs)
+							}
+						}
+						linkDir := strings.TrimSpace(os.Getenv("IN_TOTO_LINK_DIR"))
+						if len(linkDir) == 0 {
+							linkDir = "."
+						}
+						linkPath, err := writeInTotoLink(artifact, signingKeyPEM, linkDir)
+						if err != nil {
+							logger.Error(fmt.Sprintf("ABORTING: %v", err))
+							exitWith(ExitFailure)
+						}
+						logSuccess(fmt.Sprintf("Successfully created in-toto link %s", linkPath))
+					}
+
+					// notarize the current workflow file as well, once for all the artifacts (if enabled)
+					if getEnvBool("NOTARIZE_WORKFLOW") && !workflowNotarized {
+						workflowFile, err := currentWorkflowFile()
+						if err != nil {
+							logger.Error(fmt.Sprintf("ABORTING: %v", err))
+							exitWith(ExitInvalidArgs)
+						}
+						workflowArtifact, err := vcnArtifactFromWorkflowFile(pathToRepo, workflowFile)
+						if err != nil {
+							logger.Error(fmt.Sprintf("ABORTING: %v", err))
+							exitWith(ExitFailure)
+						}
+						if err := notarize(workflowArtifact, options); err != nil {
+							logger.Error(fmt.Sprintf("ABORTING: workflow notarization error: %v", err))
+							exitWith(ExitNotarizationError)
+						}
+						logSuccess(fmt.Sprintf(
+							"Successfully notarized workflow %s for current approver %s", workflowFile, approver))
+						workflowNotarized = true
+					}
+				}
+			} else {
+				logSuccess(fmt.Sprintf(
+					"SKIPPING notarization: PR approver %s is not required", approver))
+			}
+
+			// verify if the git repository was notarized for every required PR approver
+			var notarizedApprovers []string
+			var excusedRequiredApprovers []string
+			// the required approvers which have notarized the PR as untrusted fail the verification
+			var vetoingApprovers []string
+			// the required approvers which have notarized the PR with different action inputs
+			var inputsDriftApprovers []string
+			var approverDetails map[string]*vcnAPI.LcArtifact
+			retryOnLedgerInconsistency := getEnvBool("RETRY_ON_LEDGER_INCONSISTENCY")
+			maxConsistencyRetries := getEnvUint("MAX_CONSISTENCY_RETRIES", 3)
+			consistencyRetryDelay := getEnvDuration("CONSISTENCY_RETRY_DELAY", 5*time.Second)
+			verifyGitHubRunID := getEnvBool("VERIFY_GITHUB_RUN_ID")
+			// print one line per required approver instead of the verification details (if enabled)
+			compactOutput := getEnvBool("COMPACT_OUTPUT")
+			logger.Info(fmt.Sprintf(
+				"\nVerifying if the PR has been notarized for all %d required PR approvers ...",
+				len(apiKeyPerRequiredApprover)+nbPendingAPIKeys))
+			if retryOnLedgerInconsistency {
+				// the keys of all required approvers are needed to re-run the verification
+				if err := receiveAPIKeys(pendingAPIKeys, nbPendingAPIKeys, apiKeyPerRequiredApprover); err != nil {
+					logger.Error(fmt.Sprintf("ABORTING: %v", err))
+					exitWith(ExitAPIError)
+				}
+				pendingAPIKeys, nbPendingAPIKeys = nil, 0
+			}
+		verification:
+			for attempt := uint64(0); ; attempt++ {
+				notarizedApprovers, excusedRequiredApprovers, vetoingApprovers = nil, nil, nil
+				inputsDriftApprovers = nil
+				approverDetails = make(map[string]*vcnAPI.LcArtifact)
+				verifications, err := verifyApprovers(
+					artifact,
+					options,
+					apiKeysQueue(apiKeyPerRequiredApprover, pendingAPIKeys),
+					apiKeyPerRequiredApprover,
+					excusedApprovers,
+					verificationHooks{
+						pre:  strings.TrimSpace(os.Getenv("PRE_VERIFY_HOOK")),
+						post: strings.TrimSpace(os.Getenv("POST_VERIFY_HOOK")),
+					},
+				)
+				if err != nil {
+					logger.Error(fmt.Sprintf("ABORTING: %v", err))
+					exitWith(ExitVerificationError)
+				}
+				pendingAPIKeys, nbPendingAPIKeys = nil, 0
+				for _, verification := range verifications {
+					requiredApprover := verification.approver
+
+					if verification.excused {
+						if compactOutput {
+							logger.Info(compactLine("EXCUSED", red, requiredApprover+identitySuffix))
+						} else {
+							logger.Error(fmt.Sprintf(
+								"\n   EXCUSED required approver %s: PR notarization is NOT verified",
+								requiredApprover))
+						}
+						excusedRequiredApprovers = append(excusedRequiredApprovers, requiredApprover)
+						continue
+					}
+
+					if !compactOutput {
+						logger.Info(fmt.Sprintf(
+							"\n   Verifying if the PR has been notarized for %s ...",
+							requiredApprover))
+					}
+
+					cnilArtifact, err := verification.cnilArtifact, verification.err
+					if errors.Is(err, errLedgerInconsistent) && retryOnLedgerInconsistency {
+						if attempt < maxConsistencyRetries {
+							logger.Warn(fmt.Sprintf(
+								"   CNIL verification failed for required approver %s, retrying the "+
+									"verification of all required approvers in %s (retry %d of %d) ...",
+								requiredApprover, consistencyRetryDelay, attempt+1, maxConsistencyRetries))
+							time.Sleep(consistencyRetryDelay)
+							continue verification
+						}
+						logger.Error(fmt.Sprintf(
+							"   ABORTING: error verifying PR for required approver %s: %v\n"+
+								"   The CNIL verification still failed after %d retries. CNIL is backed by immudb, "+
+								"which proves on each read that the ledger state is consistent with the state "+
+								"previously verified by the client (consistency proof) and that the artifact is "+
+								"included in it (inclusion proof). A persistent failure means that these proofs "+
+								"could not be verified: the ledger may have been tampered with, or the local "+
+								"state in the VCN store %s may be out of sync with the ledger.",
+							requiredApprover, err, maxConsistencyRetries, options.storeDir))
+						exitWith(ExitVerificationError)
+					}
+					if err != nil {
+						logger.Error(fmt.Sprintf(
+							"   ABORTING: error verifying PR for required approver %s: %v",
+							requiredApprover, err))
+						exitWith(ExitVerificationError)
+					}
+					if cnilArtifact == nil {
+						if compactOutput {
+							logger.Info(compactLine("MISSING", yellow, requiredApprover+identitySuffix))
+						} else {
+							logger.Warn(fmt.Sprintf(
+								"   PR is NOT notarized for required approver %s", requiredApprover))
+						}
+						continue
+					}
+
+					// the notarizations failing the local checks are missing, not vetoes
+					if verification.invalid != nil {
+						if compactOutput {
+							logger.Info(compactLine("INVALID", yellow, cnilArtifact.Signer, verification.invalid.Error()))
+						} else {
+							logger.Warn(fmt.Sprintf(
+								"   PR notarization for required approver %s is INVALID, it is ignored: %v",
+								requiredApprover, verification.invalid))
+						}
+						continue
+					}
+
+					// the notarizations of other runs are missing, and not reported
+					if verifyGitHubRunID && !matchesGitHubRunID(cnilArtifact, os.Getenv("GITHUB_RUN_ID")) {
+						if compactOutput {
+							logger.Info(compactLine("OTHER RUN", yellow, cnilArtifact.Signer))
+						} else {
+							logger.Warn(fmt.Sprintf(
+								"   PR is NOT notarized for required approver %s in the current GitHub run %s",
+								requiredApprover, os.Getenv("GITHUB_RUN_ID")))
+						}
+						continue
+					}
+
+					approverDetails[requiredApprover] = cnilArtifact
+
+					if fingerprintInputs && !verifyWorkflowInputsHash(cnilArtifact, inputsHash) {
+						inputsDriftApprovers = append(inputsDriftApprovers, requiredApprover)
+						logger.Warn(fmt.Sprintf(
+							"   WARNING: PR has been notarized for required approver %s with different action inputs",
+							requiredApprover))
+					}
+
+					switch cnilArtifact.Status {
+					case vcnMeta.StatusTrusted:
+						notarizedApprovers = append(notarizedApprovers, requiredApprover)
+					case vcnMeta.StatusUntrusted:
+						vetoingApprovers = append(vetoingApprovers, requiredApprover)
+						logger.Error(fmt.Sprintf(
+							"   PR has been VETOED by required approver %s: it is notarized as untrusted",
+							requiredApprover))
+					}
+
+					if compactOutput {
+						logger.Info(compactVerificationLine(cnilArtifact))
+						continue
+					}
+
+					cnilArtifactDetails := fmt.Sprintf(`
+      Status:     %s
+      PR commit:  %s
+      Signer ID:  %s
+`,
+						coloredStatus(cnilArtifact.Status),
+						cnilArtifact.Name,
+						cnilArtifact.Signer)
+
+					logger.Info(fmt.Sprintf(
+						"   Verification details for approver %s: %s", requiredApprover, cnilArtifactDetails))
+
+				}
+				break
+			}
+			logger.Info("")
+
+			// make sure the required approvers list has not changed since the last notarization
+			latestHash, latestApprover := latestApproversHash(approverDetails, approver)
+			if len(latestHash) > 0 && latestHash != approversHash {
+				message := fmt.Sprintf(
+					"the list of required approvers has changed since the last notarization (by %s): "+
+						"approvers have been added or removed", latestApprover)
+				if getEnvBool("FAIL_ON_APPROVER_LIST_CHANGE") {
+					logger.Error(fmt.Sprintf("ABORTING: %s", message))
+					exitWith(ExitVerificationError)
+				}
+				logger.Warn(fmt.Sprintf("WARNING: %s", message))
+			}
+
+			// make sure the PR has been notarized with the same action inputs (if required)
+			sort.Strings(inputsDriftApprovers)
+			if len(inputsDriftApprovers) > 0 && getEnvBool("FAIL_ON_INPUTS_DRIFT") {
+				logger.Error(fmt.Sprintf(
+					"ABORTING: the PR has been notarized with different action inputs by required approver(s) %s",
+					strings.Join(inputsDriftApprovers, ", ")))
+				exitWith(ExitVerificationError)
+			}
+
+			// make sure the required approvers have notarized the PR in the expected order (if any)
+			if approverOrder := strings.TrimSpace(os.Getenv("REQUIRED_APPROVER_ORDER")); len(approverOrder) > 0 {
+				if err := checkApproverOrder(splitRequiredApprovers(approverOrder), approverDetails); err != nil {
+					logger.Error(fmt.Sprintf("ABORTING: %v", err))
+					exitWith(ExitVerificationError)
+				}
+			}
+
+			// make sure the PR has not been notarized in another approval context (if required)
+			if getEnvBool("REQUIRE_ARTIFACT_UNIQUENESS") {
+				if cnilAPIOptions == nil {
+					logger.Error("ABORTING: REQUIRE_ARTIFACT_UNIQUENESS requires the CNIL REST API personal token " +
+						"and ledger ID instead of API keys")
+					exitWith(ExitInvalidArgs)
+				}
+				signers, err := listArtifactSigners(cnilAPIOptions, artifact.Hash)
+				if err != nil {
+					logger.Error(fmt.Sprintf(
+						"ABORTING: error listing the signers of PR artifact %s: %v", artifact.Hash, err))
+					exitWith(ExitAPIError)
+				}
+				if foreign := foreignSigners(signers, apiKeyPerRequiredApprover); len(foreign) > 0 {
+					logger.Error(fmt.Sprintf(
+						"ABORTING: PR artifact %s has also been notarized by signer(s) which are not required "+
+							"approvers, i.e. it has been approved in another context: %s",
+						artifact.Hash, strings.Join(foreign, ", ")))
+					exitWith(ExitVerificationError)
+				}
+			}
+
+			if len(excusedRequiredApprovers) > 0 {
+				logger.Warn(fmt.Sprintf(
+					"WARNING: %d required approver(s) have been excused until %s: %s",
+					len(excusedRequiredApprovers), os.Getenv("EXCUSE_EXPIRES_AT"),
+					strings.Join(excusedRequiredApprovers, ",")))
+			}
+			// excusing all the required approvers must not bypass the verification
+			if len(excusedRequiredApprovers) > 0 && len(excusedRequiredApprovers) == len(apiKeyPerRequiredApprover) {
+				logger.Error("ABORTING: all the required approvers have been excused, " +
+					"the PR must be notarized by at least one of them")
+				exitWith(ExitNotApproved)
+			}
+
+			requiredApproversArr := make([]string, 0, len(apiKeyPerRequiredApprover))
+			for requiredApprover := range apiKeyPerRequiredApprover {
+				requiredApproversArr = append(requiredApproversArr, requiredApprover)
+			}
+			sort.Strings(requiredApproversArr)
+			result = newNotarizationResult(
+				artifact, requiredApproversArr, notarizedApprovers, excusedRequiredApprovers, approverDetails,
+				int(minApprovals))
+			if len(vetoingApprovers) > 0 {
+				result.vetoingApprovers = vetoingApprovers
+				result.success = false
+			}
+			result.repository = os.Getenv("GITHUB_REPOSITORY")
+			result.ledgerID = ledgerID
+			result.cnilServerVersion = cnilServerVersion
+			result.vcnVersion = vcnLibraryVersion()
+			result.mock = cnilMockMode
+			result.inputsDriftApprovers = inputsDriftApprovers
+			if cnilAPIOptions != nil {
+				result.keyRotationCount = cnilAPIOptions.keyRotations.total()
+			}
+			if len(artifactPaths) > 1 {
+				printResult(result)
+			}
+		})
+		if exitCode != 0 {
+			if failureExitCode == 0 {
+				failureExitCode = exitCode
+			}
+			continue
+		}
+		results = append(results, result)
+	}
+	if failureExitCode != 0 {
+		exitWith(failureExitCode)
+	}
+	artifactHashes := make([]string, 0, len(results))
+	for _, artifactResult := range results {
+		artifactHashes = append(artifactHashes, artifactResult.artifactHash)
+	}
+
+	// verify the Sigstore bundle of the PR as well (if specified): it must be the bundle of
+	// one of the artifacts
+	if bundlePath := strings.TrimSpace(os.Getenv("SIGSTORE_BUNDLE_PATH")); len(bundlePath) > 0 {
+		logger.Info("Verifying the Sigstore bundle of the PR ...")
+		bundle, err := ioutil.ReadFile(bundlePath)
+		if err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: error reading Sigstore bundle %s: %v", bundlePath, err))
+			exitWith(ExitInvalidArgs)
+		}
+		if err := verifyWithSigstoreAny(bundle, artifactHashes); err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: Sigstore bundle %s verification failed: %v", bundlePath, err))
+			exitWith(ExitVerificationError)
+		}
+		logSuccess("Successfully verified the Sigstore bundle of the PR")
+	}
+
+	// record the notarization in the GitHub attestations of the repository (if enabled)
+	if createAttestation && cnilMockMode {
+		logger.Warn("SKIPPING GitHub attestation: CNIL mock mode is enabled")
+	} else if createAttestation && len(notarizedArtifactHashes) > 0 {
+		bundlePath := strings.TrimSpace(os.Getenv("SIGSTORE_BUNDLE_PATH"))
+		bundle, err := ioutil.ReadFile(bundlePath)
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: error reading Sigstore bundle %s: %v", bundlePath, err))
+			exitWith(ExitInvalidArgs)
+		}
+		// GitHub only checks the bundle format: only publish a bundle of a notarized artifact
+		// which chains to the Sigstore trusted root
+		if err := verifyWithSigstoreAny(bundle, notarizedArtifactHashes); err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: Sigstore bundle %s verification failed: %v", bundlePath, err))
+			exitWith(ExitVerificationError)
+		}
+		githubAPIOptions, err := newGitHubOptions(newHTTPClient(tlsConfig))
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitInvalidArgs)
+		}
+		attestationID, err := createGitHubAttestation(githubAPIOptions, bundle)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: error creating the GitHub attestation: %v", err))
+		} else {
+			logSuccess(fmt.Sprintf("Successfully created GitHub attestation %d", attestationID))
+		}
+	}
+
+	result := mergeNotarizationResults(results)
+	if cnilAPIOptions != nil {
+		maxRotations := int(getEnvUint("MAX_ROTATIONS_PER_RUN", defaultMaxRotationsPerRun))
+		if signerIDs := cnilAPIOptions.keyRotations.exceeding(maxRotations); len(signerIDs) > 0 {
+			logger.Warn(fmt.Sprintf(
+				"WARNING: the API key of the following signer(s) has been rotated more than %d time(s) in this run: %s",
+				maxRotations, strings.Join(signerIDs, ", ")))
+		}
+	}
+	report(result)
+
+	// the step summary is purely informative: errors are ignored
+	if summaryPath := os.Getenv("GITHUB_STEP_SUMMARY"); len(summaryPath) > 0 {
+		_ = writeStepSummary(summaryPath, result)
+	}
+
+	if outputPath := os.Getenv("GITHUB_OUTPUT"); len(outputPath) > 0 {
+		if err := writeGitHubOutputs(outputPath, result); err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: %v", err))
+		}
 	}
 
-	// create VCN artifact from the git repository folder
-	artifact, err := vcnArtifactFromGitRepo()
-	if err != nil {
-		fmt.Printf(red, fmt.Sprintf(
-			"ABORTING: error creating VCN artifact from git repo %s: %v\n", pathToRepo, err))
-		os.Exit(1)
+	// the mock results must not be mistaken for actual ones
+	if cnilMockMode {
+		logger.Warn("SKIPPING publication of the result: CNIL mock mode is enabled")
+	} else {
+		publishResult(result, tlsConfig)
 	}
 
-	// make sure the local VCN store directory exists
-	options := &vcnOptions{
-		storeDir: "./.vcn",
-		cnilHost: cnilHost,
-		cnilPort: cnilgRPCPort,
-		noTLS:    noTLS,
+	// DO NOT succeed if the git repository IS NOT notarized for all required PR approvers
+	if !result.success {
+		printResult(result)
+		exitWith(ExitNotApproved)
 	}
-	if err := os.MkdirAll(options.storeDir, os.ModePerm); err != nil {
-		fmt.Printf(red, fmt.Sprintf(
-			"error creating VCN local store directory %s: %v\n", options.storeDir, err))
+
+	// notarize a manifest of the files changed by the PR (if enabled)
+	snapshotKey, isRequiredApprover := apiKeyPerRequiredApprover[approver]
+	switch {
+	case !getEnvBool("SNAPSHOT_CHANGED_FILES"):
+	case !isRequiredApprover:
+		logger.Warn(fmt.Sprintf(
+			"SKIPPING approved files manifest: PR approver %s is not required", approver))
+	case options.dryRun:
+		logger.Warn(fmt.Sprintf(
+			"SKIPPING approved files manifest: dry run mode, it is not notarized for approver %s", approver))
+	default:
+		baseRef := "HEAD~1"
+		if baseBranch := os.Getenv("GITHUB_BASE_REF"); len(baseBranch) > 0 {
+			baseRef = "origin/" + baseBranch
+		}
+		changedFiles, err := gitChangedFiles(pathToRepo, baseRef)
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitFailure)
+		}
+		// the manifest is written outside of the git checkout, which it would leave dirty
+		manifestPath := strings.TrimSpace(os.Getenv("APPROVED_FILES_MANIFEST_PATH"))
+		if len(manifestPath) == 0 {
+			tempDir := strings.TrimSpace(os.Getenv("RUNNER_TEMP"))
+			if len(tempDir) == 0 {
+				tempDir = os.TempDir()
+			}
+			manifestPath = filepath.Join(tempDir, approvedFilesManifestName)
+		}
+		manifestArtifact, err := writeApprovedFilesManifest(pathToRepo, changedFiles, manifestPath)
+		if err != nil {
+			logger.Error(fmt.Sprintf("ABORTING: %v", err))
+			exitWith(ExitFailure)
+		}
+		options.cnilAPIKey = snapshotKey
+		if err := notarize(manifestArtifact, options); err != nil {
+			logger.Error(fmt.Sprintf(
+				"ABORTING: error notarizing approved files manifest %s: %v", manifestPath, err))
+			exitWith(ExitNotarizationError)
+		}
+		logSuccess(fmt.Sprintf(
+			"Successfully notarized the manifest %s of the %d file(s) changed by the PR for approver %s",
+			manifestPath, len(changedFiles), approver))
 	}
-	// initialize VCN store
-	vcnStore.SetDir(options.storeDir)
-	vcnStore.LoadConfig()
 
-	// notarize the git repository artifact for the current PR approver (if required)
-	if notarizationKey, ok := apiKeyPerRequiredApprover[approver]; ok {
-		fmt.Println("\nNotarizing PR ...")
-		options.cnilAPIKey = notarizationKey
-		if err := notarize(artifact, options); err != nil {
-			fmt.Printf(red, fmt.Sprintf("ABORTING: notarization error: %v\n", err))
-			os.Exit(1)
+	// DO succeed if the git repository IS notarized for all required PR approvers
+	printResult(result)
+}
+
+// publishResult publishes the verification result outside of the action (if enabled): PR
+// comment, badge, check run, deployment, DefectDojo finding and review requests to the
+// missing approvers. The publication errors are only warnings.
+func publishResult(result *notarizationResult, tlsConfig *tls.Config) {
+	if getEnvBool("ACTION_POST_GITHUB_COMMENT") {
+		githubAPIOptions, err := newGitHubOptions(newHTTPClient(tlsConfig))
+		if err == nil {
+			var prNumber int
+			if prNumber, err = gitHubPullRequestNumber(); err == nil {
+				err = postResultComment(githubAPIOptions, prNumber, result)
+			}
+		}
+		if err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: error posting the result comment on the PR: %v", err))
 		}
-		fmt.Printf(green, fmt.Sprintf(
-			"Successfully notarized PR for current approver %s\n", approver))
-	} else {
-		fmt.Printf(green, fmt.Sprintf(
-			"SKIPPING notarization: PR approver %s is not required\n", approver))
 	}
 
-	// verify if the git repository was notarized for every required PR approver
-	var notarizedApprovers []string
-	fmt.Printf(
-		"\nVerifying if the PR has been notarized for all %d required PR approvers ...\n",
-		len(apiKeyPerRequiredApprover))
-	for requiredApprover, apiKey := range apiKeyPerRequiredApprover {
+	if badgePath := strings.TrimSpace(os.Getenv("BADGE_OUTPUT_PATH")); len(badgePath) > 0 {
+		if err := writeBadge(badgePath, result); err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: %v", err))
+		}
+	}
 
-		fmt.Printf(
-			"\n   Verifying if the PR has been notarized for %s ...\n",
-			requiredApprover)
+	if getEnvBool("ACTION_GITHUB_CHECK_RUN") {
+		githubAPIOptions, err := newGitHubOptions(newHTTPClient(tlsConfig))
+		if err == nil {
+			var sha string
+			if sha, err = gitHeadCommit(pathToRepo); err == nil {
+				err = createOrUpdateCheckRun(githubAPIOptions, sha, result)
+			}
+		}
+		if err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: error creating the GitHub check run: %v", err))
+		}
+	}
 
-		options.cnilAPIKey = apiKey
-		cnilArtifact, err := verify(artifact, options)
+	if environment := strings.TrimSpace(os.Getenv("GITHUB_ENVIRONMENT")); len(environment) > 0 {
+		githubAPIOptions, err := newGitHubOptions(newHTTPClient(tlsConfig))
+		if err == nil {
+			var sha string
+			if sha, err = gitHeadCommit(pathToRepo); err == nil {
+				err = createDeploymentStatus(githubAPIOptions, sha, environment, result)
+			}
+		}
 		if err != nil {
-			fmt.Printf(red, fmt.Sprintf(
-				"   ABORTING: error verifying PR for required approver %s: %v\n",
-				requiredApprover, err))
-			os.Exit(1)
-		}
-		if cnilArtifact == nil {
-			fmt.Printf(yellow, fmt.Sprintf(
-				"   PR is NOT notarized for required approver %s\n", requiredApprover))
-			continue
+			logger.Warn(fmt.Sprintf("WARNING: error creating the GitHub deployment: %v", err))
+		} else {
+			logger.Info(fmt.Sprintf("Created a GitHub deployment to environment %s", environment))
 		}
+	}
 
-		if cnilArtifact.Status == vcnMeta.StatusTrusted {
-			notarizedApprovers = append(notarizedApprovers, requiredApprover)
+	defectDojoURL := strings.TrimSpace(os.Getenv("DEFECTDOJO_URL"))
+	defectDojoAPIKey := strings.TrimSpace(os.Getenv("DEFECTDOJO_API_KEY"))
+	defectDojoProductID := strings.TrimSpace(os.Getenv("DEFECTDOJO_PRODUCT_ID"))
+	if len(defectDojoURL) > 0 && len(defectDojoAPIKey) > 0 && len(defectDojoProductID) > 0 {
+		if err := pushToDefectDojo(result, &defectDojoOptions{
+			url:        defectDojoURL,
+			apiKey:     defectDojoAPIKey,
+			productID:  defectDojoProductID,
+			httpClient: newHTTPClient(tlsConfig),
+		}); err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: error pushing the result to DefectDojo: %v", err))
+		} else {
+			logger.Info(fmt.Sprintf("Pushed the verification result to DefectDojo product %s", defectDojoProductID))
 		}
+	}
 
-		cnilArtifactDetails := fmt.Sprintf(`
-      Status:     %s
-      PR commit:  %s
-      Signer ID:  %s
-`,
-			coloredStatus(cnilArtifact.Status),
-			cnilArtifact.Name,
-			cnilArtifact.Signer)
+	// ask the missing approvers to review the PR (if enabled)
+	if !result.success && getEnvBool("REQUEST_MISSING_REVIEWS") {
+		githubAPIOptions, err := newGitHubOptions(newHTTPClient(tlsConfig))
+		if err == nil {
+			var prNumber int
+			if prNumber, err = gitHubPullRequestNumber(); err == nil {
+				err = requestReviews(githubAPIOptions, prNumber, result.missingApprovers)
+			}
+		}
+		if err != nil {
+			logger.Warn(fmt.Sprintf("WARNING: error requesting the review of the missing approvers: %v", err))
+		} else {
+			logger.Info(fmt.Sprintf("Requested the review of the missing approvers %s", strings.Join(result.missingApprovers, ", ")))
+		}
+	}
+}
 
-		fmt.Printf(
-			"   Verification details for approver %s: %s", requiredApprover, cnilArtifactDetails)
+// printApproverKeys prints the API key of each required approver, e.g. to run VCN CLI
+// commands manually with the same keys. They are printed on the standard error, as the
+// standard output is only the JSON result in JSON mode, and not buffered in quiet mode.
+func printApproverKeys(apiKeyPerRequiredApprover map[string]string) {
+	approvers := make([]string, 0, len(apiKeyPerRequiredApprover))
+	for requiredApprover := range apiKeyPerRequiredApprover {
+		approvers = append(approvers, requiredApprover)
+	}
+	sort.Strings(approvers)
 
+	logger.Warn("WARNING: the following API keys are SENSITIVE values, " +
+		"make sure this output is not kept in CI logs and rotate the keys after use")
+	fmt.Fprintf(os.Stderr, "   %-30s %s\n", "APPROVER", "API KEY")
+	for _, requiredApprover := range approvers {
+		fmt.Fprintf(os.Stderr, "   %-30s %s\n", requiredApprover, apiKeyPerRequiredApprover[requiredApprover])
 	}
-	fmt.Println("")
+}
 
-	// DO NOT succeed if the git repository IS NOT notarized for all required PR approvers
-	if len(notarizedApprovers) != len(apiKeyPerRequiredApprover) {
-		fmt.Printf(yellow, fmt.Sprintf(
-			"PR is notarized for %d of %d required approvers:\n"+
-				"   - notarized: %s\n   - required : %s",
-			len(notarizedApprovers), len(apiKeyPerRequiredApprover),
-			strings.Join(notarizedApprovers, ","), requiredApprovers))
-		os.Exit(1)
+// hasFlag returns true if the specified boolean flag is among the args, and removes it
+// from os.Args so that it is not taken as a positional arg.
+func hasFlag(name string) bool {
+	for i := 1; i < len(os.Args); i++ {
+		if os.Args[i] == name {
+			os.Args = append(os.Args[:i], os.Args[i+1:]...)
+			return true
+		}
 	}
+	return false
+}
 
-	// DO succeed if the git repository IS notarized for all required PR approvers
-	fmt.Printf(green, fmt.Sprintf(
-		"PR is notarized for all %d required approvers (%s).",
-		len(apiKeyPerRequiredApprover), requiredApprovers))
+// flagValue returns the value following the specified flag among the args (if any), and
+// removes both from os.Args so that they are not taken as positional args.
+func flagValue(name string) (string, bool) {
+	for i := 1; i+1 < len(os.Args); i++ {
+		if os.Args[i] == name {
+			value := os.Args[i+1]
+			os.Args = append(os.Args[:i], os.Args[i+2:]...)
+			return value, true
+		}
+	}
+	return "", false
 }
 
-func getArg(argIndex int, argName string, required bool, defaultVal string) string {
-	argVal := strings.TrimSpace(os.Args[argIndex])
+// resolveParam returns the value of the positional arg with the specified index if it is
+// not empty, or the value of the specified env var otherwise (if any).
+func resolveParam(argIndex int, envKey string, argName string) string {
+	var argVal string
+	if argIndex < len(os.Args) {
+		argVal = strings.TrimSpace(os.Args[argIndex])
+	}
 	// fmt.Printf("  - %s: %s (length: %d)\n", argName, argVal, len(argVal))
+	if len(argVal) == 0 && len(envKey) > 0 {
+		argVal = strings.TrimSpace(os.Getenv(envKey))
+	}
+	return argVal
+}
+
+func getArg(argIndex int, envKey string, argName string, required bool, defaultVal string) string {
+	argVal := resolveParam(argIndex, envKey, argName)
+	if required && len(argVal) == 0 && len(envKey) > 0 {
+		logger.Error(fmt.Sprintf(
+			"ABORTING: required argument value %s is empty (and %s is not set)", argName, envKey))
+		exitWith(ExitInvalidArgs)
+	}
 	if required && len(argVal) == 0 {
-		fmt.Printf(red, fmt.Sprintf("ABORTING: required argument value %s is empty\n", argName))
-		os.Exit(1)
+		logger.Error(fmt.Sprintf("ABORTING: required argument value %s is empty", argName))
+		exitWith(ExitInvalidArgs)
 	}
 	if len(argVal) == 0 && len(defaultVal) > 0 {
 		argVal = defaultVal
@@ -247,10 +2026,77 @@ func getArg(argIndex int, argName string, required bool, defaultVal string) stri
 	return argVal
 }
 
+// readFileOrDirect returns the direct value if it is not empty, or else the whitespace-trimmed
+// content of the specified file (if any), as for the secrets mounted as files by Docker or
+// Kubernetes.
+func readFileOrDirect(direct, filePath string) (string, error) {
+	if len(direct) > 0 || len(filePath) == 0 {
+		return direct, nil
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("error reading file %s: %v", filePath, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func getEnvBool(envName string) bool {
+	envVal := strings.TrimSpace(os.Getenv(envName))
+	if len(envVal) == 0 {
+		return false
+	}
+	boolVal, err := strconv.ParseBool(envVal)
+	if err != nil {
+		logger.Error(fmt.Sprintf(
+			"ABORTING: error parsing the %s environment variable value \"%s\": %v",
+			envName, envVal, err))
+		exitWith(ExitInvalidArgs)
+	}
+	return boolVal
+}
+
+func getEnvUint(envName string, defaultVal uint64) uint64 {
+	envVal := strings.TrimSpace(os.Getenv(envName))
+	if len(envVal) == 0 {
+		return defaultVal
+	}
+	uintVal, err := strconv.ParseUint(envVal, 10, 64)
+	if err != nil {
+		logger.Error(fmt.Sprintf(
+			"ABORTING: error parsing the %s environment variable value \"%s\": %v",
+			envName, envVal, err))
+		exitWith(ExitInvalidArgs)
+	}
+	return uintVal
+}
+
+func getEnvDuration(envName string, defaultVal time.Duration) time.Duration {
+	envVal := strings.TrimSpace(os.Getenv(envName))
+	if len(envVal) == 0 {
+		return defaultVal
+	}
+	durationVal, err := time.ParseDuration(envVal)
+	if err != nil {
+		logger.Error(fmt.Sprintf(
+			"ABORTING: error parsing the %s environment variable value \"%s\": %v",
+			envName, envVal, err))
+		exitWith(ExitInvalidArgs)
+	}
+	return durationVal
+}
+
 type cnilOptions struct {
-	baseURL  string
-	token    string
-	ledgerID string
+	baseURL     string
+	token       string
+	ledgerID    string
+	apiKeyScope json.RawMessage
+	orgID       string // organization of multi-tenant CNIL deployments (optional)
+	httpClient  *http.Client
+	retryPolicy *retryPolicy // retry of the transient errors (optional)
+	ledger      ledgerClient // backend-specific behaviors of the ledger
+	// archive the old API keys and create new ones instead of rotating them
+	archiveOldKeys bool
+	keyRotations   *keyRotationCounter // count of the API key rotations (optional)
 }
 
 func getAndRotateOrCreateAPIKeys(
@@ -258,19 +2104,23 @@ func getAndRotateOrCreateAPIKeys(
 	requiredApprovers string,
 	apiKeyPerRequiredApprover map[string]string,
 ) error {
+	// rotate the existing API keys, the missing ones are then created all at once
+	var signerIDsToCreate []string
 	for i, requiredApprover := range strings.Split(requiredApprovers, ",") {
 		requiredApprover = strings.TrimSpace(requiredApprover)
 		if len(requiredApprover) == 0 {
-			fmt.Printf(yellow, fmt.Sprintf(
-				"SKIPPING empty approver on position %d in the list of required approvers\n", i))
+			logger.Warn(fmt.Sprintf(
+				"SKIPPING empty approver on position %d in the list of required approvers", i))
 			continue
 		}
 		signerID := requiredApprover + identitySuffix
 		apiKey, err := getAPIKey(options, signerID)
 		if errors.Is(err, errAPIKeyNotFound) {
-			apiKey, err = createAPIKey(options, signerID)
-		} else if err == nil {
-			apiKey, err = rotateAPIKey(options, apiKey.ID)
+			signerIDsToCreate = append(signerIDsToCreate, signerID)
+			continue
+		}
+		if err == nil {
+			apiKey, err = renewAPIKey(options, apiKey, signerID)
 		}
 		if err != nil {
 			return fmt.Errorf("error getting or creating / rotating API key for approver %s: %v",
@@ -278,12 +2128,40 @@ func getAndRotateOrCreateAPIKeys(
 		}
 		apiKeyPerRequiredApprover[requiredApprover] = apiKey.Key
 	}
+	if len(signerIDsToCreate) == 0 {
+		return nil
+	}
+
+	apiKeys, err := batchCreateAPIKeys(options, signerIDsToCreate)
+	if err != nil {
+		return fmt.Errorf("error creating API keys for signers %s: %v",
+			strings.Join(signerIDsToCreate, ", "), err)
+	}
+	for _, apiKey := range apiKeys {
+		apiKeyPerRequiredApprover[strings.TrimSuffix(apiKey.Name, identitySuffix)] = apiKey.Key
+	}
 	return nil
 }
 
+func getAndRotateOrCreateAPIKey(options *cnilOptions, requiredApprover string) (string, error) {
+	signerID := requiredApprover + identitySuffix
+	apiKey, err := getAPIKey(options, signerID)
+	if errors.Is(err, errAPIKeyNotFound) {
+		apiKey, err = createAPIKey(options, signerID)
+	} else if err == nil {
+		apiKey, err = renewAPIKey(options, apiKey, signerID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting or creating / rotating API key for approver %s: %v",
+			requiredApprover, err)
+	}
+	return apiKey.Key, nil
+}
+
 type APIKeyResponse struct {
-	ID  string `json:"id"`
-	Key string `json:"key"`
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Key  string `json:"key"`
 }
 
 type APIKeysPageResponse struct {
@@ -292,10 +2170,15 @@ type APIKeysPageResponse struct {
 }
 
 func getAPIKey(options *cnilOptions, signerID string) (*APIKeyResponse, error) {
+	if cnilMockMode {
+		return mockGetAPIKey(signerID)
+	}
 	url := fmt.Sprintf(
 		"%s/api_keys/identity/%s", options.baseURL, url.PathEscape(signerID))
 	responsePayload := APIKeysPageResponse{}
 	if err := sendHTTPRequest(
+		options.httpClient,
+		options.retryPolicy,
 		http.MethodGet,
 		url,
 		options.token,
@@ -314,13 +2197,93 @@ func getAPIKey(options *cnilOptions, signerID string) (*APIKeyResponse, error) {
 }
 
 type APIKeyCreateReq struct {
-	Name     string `json:"name"`
-	ReadOnly bool   `json:"read_only"`
+	Name     string          `json:"name"`
+	ReadOnly bool            `json:"read_only"`
+	Scope    json.RawMessage `json:"scope,omitempty"`
+}
+
+// batchCreateAPIKeys creates the API keys of the specified signers with a single request,
+// or one request per key if the CNIL deployment does not support the batch creation.
+func batchCreateAPIKeys(options *cnilOptions, signerIDs []string) ([]*APIKeyResponse, error) {
+	if cnilMockMode {
+		apiKeys := make([]*APIKeyResponse, 0, len(signerIDs))
+		for _, signerID := range signerIDs {
+			apiKey, err := mockStoreAPIKey(signerID)
+			if err != nil {
+				return nil, err
+			}
+			apiKeys = append(apiKeys, apiKey)
+		}
+		return apiKeys, nil
+	}
+	url := fmt.Sprintf("%s/ledgers/%s/api_keys/batch", options.baseURL, options.ledgerID)
+	payload := make([]APIKeyCreateReq, 0, len(signerIDs))
+	for _, signerID := range signerIDs {
+		payload = append(payload, APIKeyCreateReq{Name: signerID, Scope: options.apiKeyScope})
+	}
+	payloadJSON, err := json.Marshal(&payload)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error JSON-marshaling POST %s request with payload %+v: %v",
+			url, payload, err)
+	}
+	responsePayload := APIKeysPageResponse{}
+	err = sendHTTPRequest(
+		options.httpClient,
+		options.retryPolicy,
+		http.MethodPost,
+		url,
+		options.token,
+		http.StatusCreated,
+		bytes.NewBuffer(payloadJSON),
+		&responsePayload,
+	)
+	var statusErr *unexpectedStatusError
+	if errors.As(err, &statusErr) &&
+		(statusErr.statusCode == http.StatusNotFound ||
+			statusErr.statusCode == http.StatusMethodNotAllowed ||
+			statusErr.statusCode == http.StatusBadRequest ||
+			statusErr.statusCode == http.StatusUnprocessableEntity) {
+		// the batch creation (or the API key scope) is not supported by all CNIL deployments
+		apiKeys := make([]*APIKeyResponse, 0, len(signerIDs))
+		for _, signerID := range signerIDs {
+			apiKey, err := createAPIKey(options, signerID)
+			if err != nil {
+				return nil, err
+			}
+			apiKeys = append(apiKeys, apiKey)
+		}
+		return apiKeys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if responsePayload.Total != uint64(len(signerIDs)) || len(responsePayload.Items) != len(signerIDs) {
+		return nil, fmt.Errorf("POST %s created %d API key(s) (%d returned) instead of %d",
+			url, responsePayload.Total, len(responsePayload.Items), len(signerIDs))
+	}
+	return responsePayload.Items, nil
 }
 
 func createAPIKey(options *cnilOptions, signerID string) (*APIKeyResponse, error) {
+	if cnilMockMode {
+		return mockStoreAPIKey(signerID)
+	}
+	apiKey, err := createAPIKeyWithScope(options, signerID, options.apiKeyScope)
+	var statusErr *unexpectedStatusError
+	if len(options.apiKeyScope) > 0 && errors.As(err, &statusErr) &&
+		(statusErr.statusCode == http.StatusBadRequest ||
+			statusErr.statusCode == http.StatusUnprocessableEntity) {
+		// API key scopes are not supported by all CNIL deployments
+		return createAPIKeyWithScope(options, signerID, nil)
+	}
+	return apiKey, err
+}
+
+func createAPIKeyWithScope(options *cnilOptions, signerID string, scope json.RawMessage) (*APIKeyResponse, error) {
 	url := fmt.Sprintf("%s/ledgers/%s/api_keys", options.baseURL, options.ledgerID)
-	payload := APIKeyCreateReq{Name: signerID}
+	payload := APIKeyCreateReq{Name: signerID, Scope: scope}
 	payloadJSON, err := json.Marshal(&payload)
 	if err != nil {
 		return nil, fmt.Errorf(
@@ -329,6 +2292,8 @@ func createAPIKey(options *cnilOptions, signerID string) (*APIKeyResponse, error
 	}
 	responsePayload := APIKeyResponse{}
 	if err := sendHTTPRequest(
+		options.httpClient,
+		options.retryPolicy,
 		http.MethodPost,
 		url,
 		options.token,
@@ -342,10 +2307,66 @@ func createAPIKey(options *cnilOptions, signerID string) (*APIKeyResponse, error
 	return &responsePayload, nil
 }
 
+// renewAPIKey rotates the API key of the signer, or archives it and creates a new one if
+// the old keys have to be archived (falling back to the rotation if the CNIL deployment
+// does not support archiving API keys). The successful renewals are counted as rotations.
+func renewAPIKey(options *cnilOptions, apiKey *APIKeyResponse, signerID string) (*APIKeyResponse, error) {
+	newAPIKey, err := renewAPIKeyOnce(options, apiKey, signerID)
+	if err == nil {
+		options.keyRotations.add(signerID)
+	}
+	return newAPIKey, err
+}
+
+func renewAPIKeyOnce(options *cnilOptions, apiKey *APIKeyResponse, signerID string) (*APIKeyResponse, error) {
+	if !options.archiveOldKeys {
+		return rotateAPIKey(options, apiKey.ID)
+	}
+	err := archiveAPIKey(options, apiKey.ID)
+	var statusErr *unexpectedStatusError
+	if errors.As(err, &statusErr) &&
+		(statusErr.statusCode == http.StatusNotFound ||
+			statusErr.statusCode == http.StatusMethodNotAllowed) {
+		logger.Warn(fmt.Sprintf(
+			"WARNING: archiving API keys is not supported by the CNIL deployment, rotating the API key of %s instead",
+			signerID))
+		return rotateAPIKey(options, apiKey.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return createAPIKey(options, signerID)
+}
+
+// archiveAPIKey soft-deletes the API key: it cannot be used for signing anymore, but it
+// is kept for audit purposes.
+func archiveAPIKey(options *cnilOptions, apiKeyID string) error {
+	if cnilMockMode {
+		return nil
+	}
+	url := fmt.Sprintf("%s/ledgers/%s/api_keys/%s/archive", options.baseURL, options.ledgerID, apiKeyID)
+	return sendHTTPRequest(
+		options.httpClient,
+		options.retryPolicy,
+		http.MethodPut,
+		url,
+		options.token,
+		http.StatusOK,
+		nil,
+		nil,
+	)
+}
+
 func rotateAPIKey(options *cnilOptions, apiKeyID string) (*APIKeyResponse, error) {
+	if cnilMockMode {
+		// the ID of the mocked API keys is their signer ID
+		return mockStoreAPIKey(apiKeyID)
+	}
 	url := fmt.Sprintf("%s/ledgers/%s/api_keys/%s/rotate", options.baseURL, options.ledgerID, apiKeyID)
 	responsePayload := APIKeyResponse{}
 	if err := sendHTTPRequest(
+		options.httpClient,
+		options.retryPolicy,
 		http.MethodPut,
 		url,
 		options.token,
@@ -359,7 +2380,111 @@ func rotateAPIKey(options *cnilOptions, apiKeyID string) (*APIKeyResponse, error
 	return &responsePayload, nil
 }
 
+type unexpectedStatusError struct {
+	method         string
+	url            string
+	expectedStatus int
+	status         string
+	statusCode     int
+	header         http.Header
+	body           []byte
+}
+
+func (e *unexpectedStatusError) Error() string {
+	return fmt.Sprintf("%s %s error: expected response status %d, got %s with body %s",
+		e.method, e.url, e.expectedStatus, e.status, e.body)
+}
+
+type LedgerResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type LedgersPageResponse struct {
+	Total uint64            `json:"total"`
+	Items []*LedgerResponse `json:"items"`
+}
+
+// getLedgerIDByName returns the ID of the single ledger with the specified name.
+func getLedgerIDByName(options *cnilOptions, name string) (string, error) {
+	if len(name) == 0 {
+		return "", errors.New("empty ledger name")
+	}
+	if cnilMockMode {
+		return name, nil
+	}
+	url := fmt.Sprintf("%s/ledgers?name=%s", options.baseURL, url.QueryEscape(name))
+	responsePayload := LedgersPageResponse{}
+	if err := sendHTTPRequest(
+		options.httpClient,
+		options.retryPolicy,
+		http.MethodGet,
+		url,
+		options.token,
+		http.StatusOK,
+		nil,
+		&responsePayload,
+	); err != nil {
+		return "", err
+	}
+
+	var matches []*LedgerResponse
+	for _, ledger := range responsePayload.Items {
+		if ledger.Name == name {
+			matches = append(matches, ledger)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no ledger named %s has been found", name)
+	case 1:
+		return matches[0].ID, nil
+	}
+	var matchingIDs []string
+	for _, ledger := range matches {
+		matchingIDs = append(matchingIDs, ledger.ID)
+	}
+	return "", fmt.Errorf("%d ledgers named %s have been found (IDs: %s)",
+		len(matches), name, strings.Join(matchingIDs, ", "))
+}
+
+// sendHTTPRequest sends the request, retrying it on transient errors (see
+// isTransientHTTPError) according to the retry policy (if not nil).
 func sendHTTPRequest(
+	client *http.Client,
+	retry *retryPolicy,
+	method string,
+	url string,
+	token string,
+	expectedStatus int,
+	payload io.Reader,
+	responsePayload interface{},
+) error {
+	// the payload has to be sent again on each retry
+	var payloadBytes []byte
+	if retry != nil && payload != nil {
+		var err error
+		if payloadBytes, err = ioutil.ReadAll(payload); err != nil {
+			return fmt.Errorf("error reading %s %s request payload: %v", method, url, err)
+		}
+	}
+	for attempt := uint64(0); ; attempt++ {
+		if payloadBytes != nil {
+			payload = bytes.NewReader(payloadBytes)
+		}
+		err := sendHTTPRequestOnce(client, method, url, token, expectedStatus, payload, responsePayload)
+		if retry == nil || attempt == retry.maxRetries || !isTransientHTTPError(method, err) {
+			return err
+		}
+		delay := retry.delay(attempt)
+		logger.Warn(fmt.Sprintf(
+			"   %v, retrying in %s (retry %d of %d) ...", err, delay, attempt+1, retry.maxRetries))
+		time.Sleep(delay)
+	}
+}
+
+func sendHTTPRequestOnce(
+	client *http.Client,
 	method string,
 	url string,
 	token string,
@@ -373,11 +2498,13 @@ func sendHTTPRequest(
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", "Bearer "+token)
+	if len(token) > 0 {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
 
-	response, err := (&http.Client{Timeout: httpTimeout}).Do(req)
+	response, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error sending request %s %s: %v", method, url, err)
+		return fmt.Errorf("error sending request %s %s: %w", method, url, err)
 	}
 	defer response.Body.Close()
 
@@ -387,10 +2514,20 @@ func sendHTTPRequest(
 	}
 
 	if response.StatusCode != expectedStatus {
-		return fmt.Errorf("%s %s error: expected response status %d, got %s with body %s",
-			method, url, expectedStatus, response.Status, responseBody)
+		return &unexpectedStatusError{
+			method:         method,
+			url:            url,
+			expectedStatus: expectedStatus,
+			status:         response.Status,
+			statusCode:     response.StatusCode,
+			header:         response.Header,
+			body:           responseBody,
+		}
 	}
 
+	if responsePayload == nil {
+		return nil
+	}
 	if err := json.Unmarshal(responseBody, responsePayload); err != nil {
 		return fmt.Errorf("error JSON-unmarshaling %s %s response body %s: %v",
 			method, url, responseBody, err)
@@ -405,10 +2542,27 @@ type vcnOptions struct {
 	cnilPort   string
 	cnilAPIKey string
 	noTLS      bool
+	tlsConfig  *tls.Config
+	// gRPC message size limits in bytes (0 means the gRPC default)
+	grpcMaxRecvMsgSize int
+	grpcMaxSendMsgSize int
+	// backend-specific behaviors of the ledger
+	ledger ledgerClient
+	// CAs which must have issued the signer certificate of the notarizations (optional)
+	signerCAPool *x509.CertPool
+	// only verify the PR, without notarizing it
+	dryRun bool
+	// older notarizations are ignored (0 means no maximum)
+	maxNotarizationAge time.Duration
+	// status of the notarization of the PR for the current approver
+	notarizationStatus vcnMeta.Status
 }
 
-func vcnArtifactFromGitRepo() (*vcnAPI.Artifact, error) {
-	repoURI, err := vcnURI.Parse("git://" + pathToRepo)
+func vcnArtifactFromGitRepo(repoDir string) (*vcnAPI.Artifact, error) {
+	if err := checkGitRepoDir(repoDir); err != nil {
+		return nil, err
+	}
+	repoURI, err := vcnURI.Parse("git://" + repoDir)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing path to repo: %v", err)
 	}
@@ -421,19 +2575,107 @@ func vcnArtifactFromGitRepo() (*vcnAPI.Artifact, error) {
 	return vcnArtifact[0], nil
 }
 
+// vcnArtifactFromPath creates the artifact of the specified file (hashed with SHA256) or
+// directory, which is hashed by the git extractor if it is a git repository, or by the
+// directory extractor otherwise. Relative paths are relative to the git repository.
+func vcnArtifactFromPath(path string) (*vcnAPI.Artifact, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(pathToRepo, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing artifact path: %v", err)
+	}
+
+	if !info.IsDir() {
+		hash, err := fileSHA256(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening file %s: %v", path, err)
+		}
+		defer f.Close()
+		head := make([]byte, 512)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("error reading file %s: %v", path, err)
+		}
+		return &vcnAPI.Artifact{
+			Kind:        "file",
+			Name:        filepath.Base(path),
+			Hash:        hash,
+			Size:        uint64(info.Size()),
+			ContentType: http.DetectContentType(head[:n]),
+		}, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		return vcnArtifactFromGitRepo(path)
+	}
+	dirURI, err := vcnURI.Parse("dir://" + path)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing artifact path: %v", err)
+	}
+	vcnArtifact, err := vcnDirExtractor.Artifact(dirURI)
+	if err != nil {
+		return nil, fmt.Errorf("error creating artifact: %v", err)
+	}
+	if len(vcnArtifact) == 0 {
+		return nil, fmt.Errorf("no artifact created from directory %s", path)
+	}
+	return vcnArtifact[0], nil
+}
+
+// vcnArtifactFromGitCommit creates a VCN artifact from the commit object of the specified
+// revision of the git repository at repoDir and returns it along with the commit hash.
+func vcnArtifactFromGitCommit(repoDir string, rev string) (*vcnAPI.Artifact, string, error) {
+	commitHash, commitObject, err := gitCommitObject(repoDir, rev)
+	if err != nil {
+		return nil, "", err
+	}
+	hash := sha256.Sum256(commitObject)
+	return &vcnAPI.Artifact{
+		Kind:        "git",
+		Name:        fmt.Sprintf("git://%s@%s", filepath.Base(repoDir), commitHash[:7]),
+		Hash:        hex.EncodeToString(hash[:]),
+		Size:        uint64(len(commitObject)),
+		ContentType: "application/git-commit",
+	}, commitHash, nil
+}
+
+// validateArtifactLabel makes sure that the label to be used as artifact name is at most
+// maxArtifactLabelLen characters long, without control characters nor HTML.
+func validateArtifactLabel(label string) error {
+	if utf8.RuneCountInString(label) > maxArtifactLabelLen {
+		return fmt.Errorf("the artifact label \"%s\" is longer than %d characters", label, maxArtifactLabelLen)
+	}
+	for _, r := range label {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("the artifact label %q contains control characters", label)
+		}
+		if r == '<' || r == '>' {
+			return fmt.Errorf("the artifact label \"%s\" must not contain HTML", label)
+		}
+	}
+	return nil
+}
+
 func notarize(vcnArtifact *vcnAPI.Artifact, options *vcnOptions) error {
-	vcnCNILUser, err := vcnAPI.NewLcUser(
-		options.cnilAPIKey, "", options.cnilHost, options.cnilPort, "", false, options.noTLS)
+	if cnilMockMode {
+		return nil
+	}
+	vcnCNILUser, err := newCNILUser(options)
 	if err != nil {
-		return fmt.Errorf("error initializing vcn client: %v", err)
+		return err
 	}
 	if err := vcnCNILUser.Client.Connect(); err != nil {
 		return fmt.Errorf("error connecting vcn client: %v", err)
 	}
 	defer vcnCNILUser.Client.Disconnect()
 
-	var state vcnMeta.Status
-	_, _, err = vcnCNILUser.Sign(*vcnArtifact, vcnAPI.LcSignWithStatus(state))
+	_, _, err = vcnCNILUser.Sign(*vcnArtifact, vcnAPI.LcSignWithStatus(options.notarizationStatus))
 	if err != nil {
 		return fmt.Errorf("error signing artifact: %v", err)
 	}
@@ -441,44 +2683,84 @@ func notarize(vcnArtifact *vcnAPI.Artifact, options *vcnOptions) error {
 	return nil
 }
 
-func verify(artifact *vcnAPI.Artifact, options *vcnOptions) (*vcnAPI.LcArtifact, error) {
-	vcnCNILUser, err := vcnAPI.NewLcUser(
-		options.cnilAPIKey, "", options.cnilHost, options.cnilPort, "", false, options.noTLS)
+// pingCNIL tests the connectivity to the CNIL gRPC API by loading the artifact with the
+// specified (known) hash, which does not create anything in the ledger: the artifact not
+// being found is a successful test.
+func pingCNIL(options *vcnOptions, testArtifactHash string) error {
+	if cnilMockMode {
+		return nil
+	}
+	vcnCNILUser, err := newCNILUser(options)
 	if err != nil {
-		return nil, fmt.Errorf("error initializing vcn client: %v", err)
+		return err
 	}
 	if err := vcnCNILUser.Client.Connect(); err != nil {
-		return nil, fmt.Errorf("vcn connection error: %v", err)
+		return fmt.Errorf("vcn connection error: %v", err)
 	}
 	defer vcnCNILUser.Client.Disconnect()
 
-	cnilArtifact, verified, err := vcnCNILUser.LoadArtifact(artifact.Hash, "", "", 0)
-	if err == vcnAPI.ErrNotFound {
-		return nil, nil
+	_, _, err = vcnCNILUser.LoadArtifact(testArtifactHash, "", "", 0)
+	if err != nil && err != vcnAPI.ErrNotFound {
+		return fmt.Errorf("error loading test artifact %s: %v", testArtifactHash, err)
+	}
+	return nil
+}
+
+func verify(artifact *vcnAPI.Artifact, options *vcnOptions) (*vcnAPI.LcArtifact, error) {
+	if cnilMockMode {
+		return mockVerify(artifact, options.cnilAPIKey), nil
 	}
+	vcnCNILUser, err := newCNILUser(options)
 	if err != nil {
-		return nil, fmt.Errorf("ledger might be compromised: %v", err)
+		return nil, err
+	}
+	if err := vcnCNILUser.Client.Connect(); err != nil {
+		return nil, fmt.Errorf("vcn connection error: %v", err)
 	}
+	defer vcnCNILUser.Client.Disconnect()
 
-	if !verified {
-		return nil, errors.New(
-			`ledger might be compromised: CNIL verification status is "false"`)
+	cnilArtifact, err := options.ledger.loadArtifact(vcnCNILUser, artifact.Hash)
+	if err != nil || cnilArtifact == nil {
+		return nil, err
 	}
 
 	if cnilArtifact.Revoked != nil && !cnilArtifact.Revoked.IsZero() {
 		cnilArtifact.Status = vcnMeta.StatusApikeyRevoked
 	}
 
+	if err := checkNotarizationAge(cnilArtifact, options.maxNotarizationAge); err != nil {
+		logger.Warn(fmt.Sprintf("   WARNING: %v, it is ignored", err))
+		return nil, nil
+	}
+
 	return cnilArtifact, nil
 }
 
-func coloredStatus(status vcnMeta.Status) string {
-	statusColor := green
+// validateNotarization makes the local checks of a notarization loaded from the ledger: its
+// timestamp (see checkNotarizationTimestamp) and the certificate of its signer (if CAs are
+// specified). An invalid notarization does not count, but its ledger status is kept.
+func validateNotarization(cnilArtifact *vcnAPI.LcArtifact, options *vcnOptions) error {
+	if err := checkNotarizationTimestamp(cnilArtifact); err != nil {
+		return err
+	}
+	if options.signerCAPool != nil && cnilArtifact.Status == vcnMeta.StatusTrusted {
+		return verifySignerCertificate(cnilArtifact, options.signerCAPool)
+	}
+	return nil
+}
+
+// statusColor returns the color of the specified CNIL status: green if trusted, yellow if
+// the API key is revoked, red otherwise.
+func statusColor(status vcnMeta.Status) string {
 	switch status {
 	case vcnMeta.StatusUntrusted, vcnMeta.StatusUnknown, vcnMeta.StatusUnsupported:
-		statusColor = red
+		return red
 	case vcnMeta.StatusApikeyRevoked:
-		statusColor = yellow
+		return yellow
 	}
-	return fmt.Sprintf(statusColor, status)
+	return green
+}
+
+func coloredStatus(status vcnMeta.Status) string {
+	return colorize(statusColor(status), status)
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Types of the artifacts of the artifacts manifest
+const (
+	artifactTypeGit  = "git"
+	artifactTypeFile = "file"
+	artifactTypeDir  = "dir"
+)
+
+type ArtifactManifestEntry struct {
+	Type string `yaml:"type"`
+	Path string `yaml:"path"`
+}
+
+// readArtifactsManifest reads the YAML list of the artifacts to notarize and verify (see the
+// ARTIFACTS_MANIFEST env var) and returns the ARTIFACT_PATH value of each of them, which is
+// empty for the git repository of the PR. Relative paths are relative to repoDir.
+func readArtifactsManifest(manifestPath string, repoDir string) ([]string, error) {
+	content, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading artifacts manifest %s: %v", manifestPath, err)
+	}
+	var entries []*ArtifactManifestEntry
+	if err := yaml.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("error parsing artifacts manifest %s: %v", manifestPath, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("artifacts manifest %s does not list any artifact", manifestPath)
+	}
+
+	artifactPaths := make([]string, 0, len(entries))
+	for i, entry := range entries {
+		path := entry.Path
+		if len(path) == 0 {
+			path = "."
+		}
+		fullPath := path
+		if !filepath.IsAbs(fullPath) {
+			fullPath = filepath.Join(repoDir, fullPath)
+		}
+		switch entry.Type {
+		case artifactTypeGit:
+			err = checkGitRepoDir(fullPath)
+		case artifactTypeFile, artifactTypeDir:
+			var info os.FileInfo
+			if info, err = os.Stat(fullPath); err == nil {
+				if entry.Type == artifactTypeFile && !info.Mode().IsRegular() {
+					err = fmt.Errorf("%s is not a regular file", path)
+				} else if entry.Type == artifactTypeDir && !info.IsDir() {
+					err = fmt.Errorf("%s is not a directory", path)
+				}
+			}
+		default:
+			err = fmt.Errorf("unsupported artifact type \"%s\": expected %s, %s or %s",
+				entry.Type, artifactTypeGit, artifactTypeFile, artifactTypeDir)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact #%d of artifacts manifest %s: %v", i+1, manifestPath, err)
+		}
+		if entry.Type == artifactTypeGit && filepath.Clean(fullPath) == filepath.Clean(repoDir) {
+			path = ""
+		}
+		artifactPaths = append(artifactPaths, path)
+	}
+	return artifactPaths, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTestFiles writes the specified files, by path relative to dir, creating their
+// parent directories.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadArtifactsManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name: "all artifact types",
+			manifest: "- type: git\n" +
+				"- type: file\n  path: dist/app.tar.gz\n" +
+				"- type: dir\n  path: charts\n" +
+				"- type: git\n  path: modules/lib\n",
+			want: []string{"", "dist/app.tar.gz", "charts", "modules/lib"},
+		},
+		{
+			name:     "git repository of the PR by path",
+			manifest: "- type: git\n  path: .\n",
+			want:     []string{""},
+		},
+		{
+			name:     "empty manifest",
+			manifest: "[]\n",
+			wantErr:  "does not list any artifact",
+		},
+		{
+			name:     "unsupported type",
+			manifest: "- type: docker\n  path: app\n",
+			wantErr:  "unsupported artifact type \"docker\"",
+		},
+		{
+			name:     "file which is a directory",
+			manifest: "- type: file\n  path: charts\n",
+			wantErr:  "charts is not a regular file",
+		},
+		{
+			name:     "directory which is a file",
+			manifest: "- type: dir\n  path: dist/app.tar.gz\n",
+			wantErr:  "dist/app.tar.gz is not a directory",
+		},
+		{
+			name:     "git path which is not a repository",
+			manifest: "- type: git\n  path: charts\n",
+			wantErr:  "is not a git repository",
+		},
+		{
+			name:     "missing file",
+			manifest: "- type: git\n- type: file\n  path: missing.txt\n",
+			wantErr:  "invalid artifact #2",
+		},
+		{
+			name:     "invalid YAML",
+			manifest: "type: git\n",
+			wantErr:  "error parsing artifacts manifest",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repoDir := t.TempDir()
+			writeTestFiles(t, repoDir, map[string]string{
+				".git/HEAD":              "ref: refs/heads/main\n",
+				"dist/app.tar.gz":        "archive",
+				"charts/Chart.yaml":      "name: app\n",
+				"modules/lib/.git/HEAD":  "ref: refs/heads/main\n",
+				"artifacts-manifest.yml": test.manifest,
+			})
+			got, err := readArtifactsManifest(filepath.Join(repoDir, "artifacts-manifest.yml"), repoDir)
+			if len(test.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("readArtifactsManifest() error = %v, want error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readArtifactsManifest: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("readArtifactsManifest() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
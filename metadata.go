@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+const (
+	metadataGitHubRunID      = "github_run_id"
+	metadataGitHubRunAttempt = "github_run_attempt"
+	metadataBaseCommit       = "base_commit"
+	metadataInputsHash       = "workflow_inputs_hash"
+	metadataApproversHash    = "required_approvers_hash"
+	// PEM-encoded x509 certificate of the signer (if any)
+	metadataSignerCertificate = "signer_certificate"
+	metadataReviewCommentHash = "review_comment_hash"
+	metadataActionConfigHash  = "action_config_hash"
+	metadataCommitSummaries   = "commit_summaries"
+	// SHA256 hash of each dependency lockfile, by path relative to the repository
+	metadataDependencyLockfiles = "dependency_lockfiles"
+)
+
+const (
+	defaultMaxCommitSummaries = 10
+	maxCommitSummaryLength    = 100
+)
+
+// buildActionConfigHash is the SHA256 of the action definition files the action is built
+// from (action.yml and Dockerfile), set at build time with -ldflags
+// "-X main.buildActionConfigHash=<hash>". It is the SHA256 of the output of
+// "sha256sum action.yml Dockerfile", so that it can be recomputed from the action repository.
+var buildActionConfigHash string
+
+// truncateCommitSummaries truncates each of the commit summaries to maxCommitSummaryLength
+// characters, so that they only give an overview of the PR content.
+func truncateCommitSummaries(summaries []string) []string {
+	truncated := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		if runes := []rune(summary); len(runes) > maxCommitSummaryLength {
+			summary = string(runes[:maxCommitSummaryLength])
+		}
+		truncated = append(truncated, summary)
+	}
+	return truncated
+}
+
+// parsePerApproverMetadata parses a JSON object mapping approvers to the metadata
+// to be added to the artifact they sign, e.g. {"alice": {"role": "security-lead"}}.
+func parsePerApproverMetadata(metadataJSON string) (map[string]vcnAPI.Metadata, error) {
+	metadataPerApprover := make(map[string]vcnAPI.Metadata)
+	if len(metadataJSON) == 0 {
+		return metadataPerApprover, nil
+	}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadataPerApprover); err != nil {
+		return nil, fmt.Errorf("error JSON-unmarshaling per-approver metadata %s: %v", metadataJSON, err)
+	}
+	return metadataPerApprover, nil
+}
+
+// mergeMetadata adds the specified metadata to the artifact, overwriting existing values.
+func mergeMetadata(artifact *vcnAPI.Artifact, metadata vcnAPI.Metadata) {
+	if len(metadata) == 0 {
+		return
+	}
+	if artifact.Metadata == nil {
+		artifact.Metadata = vcnAPI.Metadata{}
+	}
+	for k, v := range metadata {
+		artifact.Metadata[k] = v
+	}
+}
+
+// gitHubRunMetadata returns the metadata linking a notarization to the current GitHub
+// Actions run (empty when not running in GitHub Actions).
+func gitHubRunMetadata() vcnAPI.Metadata {
+	metadata := vcnAPI.Metadata{}
+	if runID := os.Getenv("GITHUB_RUN_ID"); len(runID) > 0 {
+		metadata[metadataGitHubRunID] = runID
+	}
+	if runAttempt := os.Getenv("GITHUB_RUN_ATTEMPT"); len(runAttempt) > 0 {
+		metadata[metadataGitHubRunAttempt] = runAttempt
+	}
+	return metadata
+}
+
+// matchesGitHubRunID returns true if the notarization has been made from the GitHub
+// Actions run with the specified ID.
+func matchesGitHubRunID(cnilArtifact *vcnAPI.LcArtifact, runID string) bool {
+	notarizationRunID, _ := cnilArtifact.Metadata[metadataGitHubRunID].(string)
+	return len(runID) > 0 && notarizationRunID == runID
+}
+
+// workflowInputsHash returns the hex-encoded SHA256 hash of the specified action inputs
+// (which must not include secrets such as tokens or API keys), independently of their order.
+func workflowInputsHash(inputs map[string]string) string {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, name+"="+inputs[name])
+	}
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(hash[:])
+}
+
+// verifyWorkflowInputsHash returns true if the notarization has been made with the same
+// action inputs as the ones with the specified hash.
+func verifyWorkflowInputsHash(artifact *vcnAPI.LcArtifact, currentHash string) bool {
+	notarizationHash, _ := artifact.Metadata[metadataInputsHash].(string)
+	return notarizationHash == currentHash
+}
+
+// requiredApproversHash returns the hex-encoded SHA256 hash of the sorted comma-separated
+// list of required approvers, ignoring empty and duplicate entries.
+func requiredApproversHash(requiredApprovers string) string {
+	seen := make(map[string]struct{})
+	var sorted []string
+	for _, requiredApprover := range strings.Split(requiredApprovers, ",") {
+		requiredApprover = strings.TrimSpace(requiredApprover)
+		if _, ok := seen[requiredApprover]; ok || len(requiredApprover) == 0 {
+			continue
+		}
+		seen[requiredApprover] = struct{}{}
+		sorted = append(sorted, requiredApprover)
+	}
+	sort.Strings(sorted)
+	hash := sha256.Sum256([]byte(strings.Join(sorted, ",")))
+	return hex.EncodeToString(hash[:])
+}
+
+// latestApproversHash returns the required approvers hash stored in the most recent of the
+// specified notarizations which has one, along with its approver. The notarization of the
+// excluded approver (i.e. the current one, which may just have been made) is ignored.
+func latestApproversHash(approverDetails map[string]*vcnAPI.LcArtifact, excludedApprover string) (string, string) {
+	var latest *vcnAPI.LcArtifact
+	var latestHash, latestApprover string
+	for requiredApprover, notarization := range approverDetails {
+		if notarization == nil || requiredApprover == excludedApprover {
+			continue
+		}
+		hash, ok := notarization.Metadata[metadataApproversHash].(string)
+		if !ok || (latest != nil && !notarization.Timestamp.After(latest.Timestamp)) {
+			continue
+		}
+		latest, latestHash, latestApprover = notarization, hash, requiredApprover
+	}
+	return latestHash, latestApprover
+}
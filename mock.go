@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+	vcnMeta "github.com/vchain-us/vcn/pkg/meta"
+)
+
+// cnilMockMode replaces the CNIL REST and gRPC API calls with in-memory stubs (see the
+// CNIL_MOCK_MODE env var), to test the workflow configuration without a CNIL instance.
+var cnilMockMode bool
+
+// mockCNIL is the in-memory state of the CNIL stubs: the API keys per signer ID and the
+// signer ID per API key. The pipelined API key mode uses it concurrently.
+var mockCNIL = struct {
+	sync.Mutex
+	apiKeys       map[string]*APIKeyResponse
+	signerIDByKey map[string]string
+}{
+	apiKeys:       make(map[string]*APIKeyResponse),
+	signerIDByKey: make(map[string]string),
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("error generating random UUID: %v", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// mockStoreAPIKey generates a new API key for the specified signer, replacing its current one.
+func mockStoreAPIKey(signerID string) (*APIKeyResponse, error) {
+	key, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	mockCNIL.Lock()
+	defer mockCNIL.Unlock()
+	apiKey := &APIKeyResponse{ID: signerID, Name: signerID, Key: key}
+	if previous, ok := mockCNIL.apiKeys[signerID]; ok {
+		delete(mockCNIL.signerIDByKey, previous.Key)
+	}
+	mockCNIL.apiKeys[signerID] = apiKey
+	mockCNIL.signerIDByKey[key] = signerID
+	return apiKey, nil
+}
+
+// mockGetAPIKey returns the API key of the specified signer, which is generated on the
+// first call, so that it is never missing.
+func mockGetAPIKey(signerID string) (*APIKeyResponse, error) {
+	mockCNIL.Lock()
+	apiKey, ok := mockCNIL.apiKeys[signerID]
+	mockCNIL.Unlock()
+	if ok {
+		return apiKey, nil
+	}
+	return mockStoreAPIKey(signerID)
+}
+
+// mockVerify returns a trusted notarization of the artifact by the signer of the API key.
+func mockVerify(artifact *vcnAPI.Artifact, apiKey string) *vcnAPI.LcArtifact {
+	mockCNIL.Lock()
+	signerID, ok := mockCNIL.signerIDByKey[apiKey]
+	mockCNIL.Unlock()
+	if !ok {
+		signerID = "mock" + identitySuffix
+	}
+	return &vcnAPI.LcArtifact{
+		Kind:        artifact.Kind,
+		Name:        artifact.Name,
+		Hash:        artifact.Hash,
+		Size:        artifact.Size,
+		ContentType: artifact.ContentType,
+		Signer:      signerID,
+		Metadata:    artifact.Metadata,
+		Timestamp:   time.Now().UTC(),
+		Status:      vcnMeta.StatusTrusted,
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// newActionResultJSON creates the JSON result of the action with the specified exit code:
+// the JSON representation of the result of the verification (if the action got that far),
+// otherwise only the success and, on failure, the last error message.
+func newActionResultJSON(exitCode ExitCode, result *notarizationResult, lastError string) *NotarizationResultJSON {
+	resultJSON := &NotarizationResultJSON{Success: exitCode == 0}
+	if result != nil {
+		resultJSON = newNotarizationResultJSON(result)
+	}
+	if exitCode != 0 {
+		resultJSON.Success = false
+		resultJSON.Error = lastError
+	}
+	return resultJSON
+}
+
+// quietSuccessMessage returns the final message of a successful action in quiet mode,
+// from its JSON result.
+func quietSuccessMessage(resultJSON *NotarizationResultJSON) string {
+	if len(resultJSON.ArtifactHash) == 0 {
+		return "PR verification skipped"
+	}
+	message := fmt.Sprintf("PR notarized for %d of %d required approvers (artifact hash %s)",
+		len(resultJSON.NotarizedApprovers), len(resultJSON.RequiredApprovers), resultJSON.ArtifactHash)
+	if resultJSON.Mock {
+		message += ", in CNIL mock mode"
+	}
+	return message
+}
+
+// printQuietOutput prints the buffered output of the quiet mode on failure, otherwise only
+// the specified final success message.
+func printQuietOutput(output string, success bool, successMessage string) {
+	if success {
+		output = successMessage
+	}
+	output = strings.TrimRight(output, "\n")
+	if len(output) > 0 {
+		fmt.Println(output)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type approverAPIKey struct {
+	approver string
+	apiKey   string
+	err      error
+}
+
+// rotateAPIKeysPipelined gets and rotates or creates the API keys of the required
+// approvers in a separate goroutine and sends them on the returned channel as soon as
+// each one is available, so that the verification of an approver overlaps with the key
+// rotation of the next ones. The current approver (if required) is handled first, so
+// that the PR can be notarized while the other keys are being rotated. The channel is
+// closed after the last key or after the first error.
+func rotateAPIKeysPipelined(
+	options *cnilOptions,
+	requiredApprovers []string,
+	currentApprover string,
+) <-chan approverAPIKey {
+	orderedApprovers := make([]string, 0, len(requiredApprovers))
+	for _, requiredApprover := range requiredApprovers {
+		if requiredApprover == currentApprover {
+			orderedApprovers = append([]string{requiredApprover}, orderedApprovers...)
+		} else {
+			orderedApprovers = append(orderedApprovers, requiredApprover)
+		}
+	}
+
+	apiKeys := make(chan approverAPIKey)
+	go func() {
+		defer close(apiKeys)
+		for _, requiredApprover := range orderedApprovers {
+			apiKey, err := getAndRotateOrCreateAPIKey(options, requiredApprover)
+			apiKeys <- approverAPIKey{approver: requiredApprover, apiKey: apiKey, err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return apiKeys
+}
+
+// receiveAPIKeys receives the specified number of pending API keys and adds them to
+// apiKeyPerRequiredApprover.
+func receiveAPIKeys(
+	pendingAPIKeys <-chan approverAPIKey,
+	nbAPIKeys int,
+	apiKeyPerRequiredApprover map[string]string,
+) error {
+	for i := 0; i < nbAPIKeys; i++ {
+		apiKey, ok := <-pendingAPIKeys
+		if !ok {
+			return errors.New("API key rotation ended unexpectedly")
+		}
+		if apiKey.err != nil {
+			return apiKey.err
+		}
+		apiKeyPerRequiredApprover[apiKey.approver] = apiKey.apiKey
+	}
+	return nil
+}
+
+// apiKeysQueue returns a channel on which the known API keys are sent first, followed by
+// the pending ones (if any).
+func apiKeysQueue(
+	apiKeyPerRequiredApprover map[string]string,
+	pendingAPIKeys <-chan approverAPIKey,
+) <-chan approverAPIKey {
+	queue := make(chan approverAPIKey, len(apiKeyPerRequiredApprover))
+	for requiredApprover, apiKey := range apiKeyPerRequiredApprover {
+		queue <- approverAPIKey{approver: requiredApprover, apiKey: apiKey}
+	}
+	if pendingAPIKeys == nil {
+		close(queue)
+		return queue
+	}
+	go func() {
+		defer close(queue)
+		for apiKey := range pendingAPIKeys {
+			queue <- apiKey
+		}
+	}()
+	return queue
+}
+
+// splitRequiredApprovers splits the comma-separated list of required approvers, skipping
+// empty and duplicate entries.
+func splitRequiredApprovers(requiredApprovers string) []string {
+	var approvers []string
+	seen := make(map[string]struct{})
+	for i, requiredApprover := range strings.Split(requiredApprovers, ",") {
+		requiredApprover = strings.TrimSpace(requiredApprover)
+		if len(requiredApprover) == 0 {
+			logger.Warn(fmt.Sprintf(
+				"SKIPPING empty approver on position %d in the list of required approvers", i))
+			continue
+		}
+		if _, ok := seen[requiredApprover]; ok {
+			continue
+		}
+		seen[requiredApprover] = struct{}{}
+		approvers = append(approvers, requiredApprover)
+	}
+	return approvers
+}
+
+// randomDuration returns a (cryptographically) random duration in [0, max].
+func randomDuration(max time.Duration) (time.Duration, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)+1))
+	if err != nil {
+		return 0, fmt.Errorf("error generating random duration: %v", err)
+	}
+	return time.Duration(n.Int64()), nil
+}
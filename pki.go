@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+// loadCertPool loads the PEM-encoded CA certificates of the specified bundle file.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	bundle, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA bundle file %s: %v", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no PEM-encoded certificate found in CA bundle file %s", path)
+	}
+	return pool, nil
+}
+
+// verifySignerCertificate makes sure that the notarization holds the certificate of its
+// signer (in its metadata), issued by one of the specified CAs for the signer ID, which has
+// to be the certificate common name or one of its email addresses.
+func verifySignerCertificate(cnilArtifact *vcnAPI.LcArtifact, roots *x509.CertPool) error {
+	certPEM, _ := cnilArtifact.Metadata[metadataSignerCertificate].(string)
+	if len(certPEM) == 0 {
+		return fmt.Errorf("the notarization by %s has no signer certificate", cnilArtifact.Signer)
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("the signer certificate of the notarization by %s is not PEM-encoded", cnilArtifact.Signer)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("error parsing the signer certificate of the notarization by %s: %v", cnilArtifact.Signer, err)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("the signer certificate of the notarization by %s is not trusted: %v", cnilArtifact.Signer, err)
+	}
+	if cert.Subject.CommonName == cnilArtifact.Signer {
+		return nil
+	}
+	for _, email := range cert.EmailAddresses {
+		if email == cnilArtifact.Signer {
+			return nil
+		}
+	}
+	return errors.New("the signer certificate has not been issued for " + cnilArtifact.Signer)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// preflightCheck makes sure that the CNIL REST API (if the options are specified) and the
+// CNIL gRPC API are reachable, before spending time on the API key management. Any HTTP
+// response of the REST API but a server error proves its connectivity, as the health
+// endpoint is not exposed by all CNIL deployments.
+func preflightCheck(cnilOpts *cnilOptions, vcnOpts *vcnOptions) error {
+	if cnilMockMode {
+		return nil
+	}
+	if cnilOpts != nil {
+		url := cnilOpts.baseURL + "/health"
+		err := sendHTTPRequest(
+			cnilOpts.httpClient, cnilOpts.retryPolicy, http.MethodGet, url, "", http.StatusOK, nil, nil)
+		var statusErr *unexpectedStatusError
+		if err != nil && (!errors.As(err, &statusErr) || statusErr.statusCode >= http.StatusInternalServerError) {
+			return fmt.Errorf("CNIL REST API at %s is unreachable: %v", cnilOpts.baseURL, err)
+		}
+	}
+
+	vcnCNILUser, err := newCNILUser(vcnOpts)
+	if err != nil {
+		return err
+	}
+	if err := vcnCNILUser.Client.Connect(); err != nil {
+		return fmt.Errorf("CNIL gRPC API at %s:%s is unreachable: %v", vcnOpts.cnilHost, vcnOpts.cnilPort, err)
+	}
+	vcnCNILUser.Client.Disconnect()
+	return nil
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+type shieldsBadge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// writeBadge writes a Shields.io endpoint badge (https://shields.io/endpoint) with the
+// number of approvers which have notarized the PR to the specified file: green if the
+// verification succeeded, red if the PR is vetoed or not notarized at all, yellow otherwise.
+func writeBadge(path string, result *notarizationResult) error {
+	nbNotarized, nbRequired := len(result.notarizedApprovers), len(result.requiredApprovers)
+	message := fmt.Sprintf("%d/%d approved", nbNotarized, nbRequired)
+	var color string
+	switch {
+	case len(result.vetoingApprovers) > 0:
+		color = "red"
+		message = "vetoed"
+	case result.success:
+		color = "green"
+	case nbNotarized > 0:
+		color = "yellow"
+	default:
+		color = "red"
+	}
+	badge := shieldsBadge{
+		SchemaVersion: 1,
+		Label:         "codenotary",
+		Message:       message,
+		Color:         color,
+	}
+	badgeJSON, err := json.Marshal(&badge)
+	if err != nil {
+		return fmt.Errorf("error JSON-marshaling badge %+v: %v", badge, err)
+	}
+	if err := ioutil.WriteFile(path, badgeJSON, 0644); err != nil {
+		return fmt.Errorf("error writing badge file %s: %v", path, err)
+	}
+	return nil
+}
+
+// notarizationResult holds the outcome of the PR verification, used by all outputs.
+type notarizationResult struct {
+	repository        string
+	artifactName      string
+	artifactHash      string
+	ledgerID          string
+	cnilServerVersion string
+	vcnVersion        string
+	// keyRotationCount is the number of API keys rotated (i.e. not created nor reused)
+	keyRotationCount int
+	// requiredApprovers does not include the excused ones
+	requiredApprovers  []string
+	notarizedApprovers []string
+	missingApprovers   []string
+	excusedApprovers   []string
+	// vetoingApprovers have notarized the artifact as untrusted, which fails the verification
+	vetoingApprovers []string
+	// inputsDriftApprovers have notarized the artifact with different action inputs (see
+	// FINGERPRINT_WORKFLOW_INPUTS)
+	inputsDriftApprovers []string
+	// minApprovals is the number of notarizations required for success (the quorum)
+	minApprovals int
+	// approverDetails holds the CNIL notarization found for each required approver (if any)
+	approverDetails map[string]*vcnAPI.LcArtifact
+	success         bool
+	timestamp       time.Time
+	// artifacts holds the result of each artifact of an artifacts manifest (if any)
+	artifacts []*notarizationResult
+	// mock is true if the result comes from the CNIL stubs (see cnilMockMode)
+	mock bool
+}
+
+// newNotarizationResult creates the result of the verification of the artifact for the
+// required approvers, given the ones which have notarized it and the excused ones. It is
+// successful if at least minApprovals of the required approvers which are not excused
+// (all of them if 0, or if there are fewer) have notarized the artifact, and never without
+// any notarization, e.g. if all the required approvers are excused.
+func newNotarizationResult(
+	artifact *vcnAPI.Artifact,
+	requiredApprovers []string,
+	notarizedApprovers []string,
+	excusedApprovers []string,
+	approverDetails map[string]*vcnAPI.LcArtifact,
+	minApprovals int,
+) *notarizationResult {
+	result := &notarizationResult{
+		artifactName:       artifact.Name,
+		artifactHash:       artifact.Hash,
+		notarizedApprovers: notarizedApprovers,
+		excusedApprovers:   excusedApprovers,
+		approverDetails:    approverDetails,
+		timestamp:          time.Now().UTC(),
+	}
+	notarized := make(map[string]struct{}, len(notarizedApprovers))
+	for _, notarizedApprover := range notarizedApprovers {
+		notarized[notarizedApprover] = struct{}{}
+	}
+	excused := make(map[string]struct{}, len(excusedApprovers))
+	for _, excusedApprover := range excusedApprovers {
+		excused[excusedApprover] = struct{}{}
+	}
+	for _, requiredApprover := range requiredApprovers {
+		if _, ok := excused[requiredApprover]; ok {
+			continue
+		}
+		result.requiredApprovers = append(result.requiredApprovers, requiredApprover)
+		if _, ok := notarized[requiredApprover]; !ok {
+			result.missingApprovers = append(result.missingApprovers, requiredApprover)
+		}
+	}
+	result.minApprovals = minApprovals
+	if minApprovals == 0 || minApprovals > len(result.requiredApprovers) {
+		result.minApprovals = len(result.requiredApprovers)
+	}
+	if result.minApprovals == 0 {
+		result.minApprovals = 1
+	}
+	result.success = len(result.notarizedApprovers) >= result.minApprovals
+	return result
+}
+
+// mergeNotarizationResults merges the results of the artifacts of an artifacts manifest
+// into a single one, which only succeeds if all of them do. A required approver is only
+// notarized if they have notarized all the artifacts, and vetoes the PR if they have vetoed
+// any of them.
+func mergeNotarizationResults(results []*notarizationResult) *notarizationResult {
+	if len(results) == 1 {
+		return results[0]
+	}
+	merged := *results[0]
+	merged.notarizedApprovers, merged.missingApprovers, merged.vetoingApprovers = nil, nil, nil
+	merged.inputsDriftApprovers = nil
+	merged.approverDetails = make(map[string]*vcnAPI.LcArtifact)
+	merged.artifacts = results
+	var artifactNames, artifactHashes []string
+	nbNotarized := make(map[string]int)
+	vetoes := make(map[string]*vcnAPI.LcArtifact)
+	inputsDrifts := make(map[string]struct{})
+	for _, result := range results {
+		artifactNames = append(artifactNames, result.artifactName)
+		artifactHashes = append(artifactHashes, result.artifactHash)
+		merged.success = merged.success && result.success
+		for _, notarizedApprover := range result.notarizedApprovers {
+			nbNotarized[notarizedApprover]++
+		}
+		for _, vetoingApprover := range result.vetoingApprovers {
+			if _, ok := vetoes[vetoingApprover]; !ok {
+				vetoes[vetoingApprover] = result.approverDetails[vetoingApprover]
+			}
+		}
+		for _, inputsDriftApprover := range result.inputsDriftApprovers {
+			inputsDrifts[inputsDriftApprover] = struct{}{}
+		}
+		if result.keyRotationCount > merged.keyRotationCount {
+			merged.keyRotationCount = result.keyRotationCount
+		}
+		if result.timestamp.After(merged.timestamp) {
+			merged.timestamp = result.timestamp
+		}
+	}
+	merged.artifactName = strings.Join(artifactNames, ", ")
+	merged.artifactHash = strings.Join(artifactHashes, ",")
+	for _, requiredApprover := range merged.requiredApprovers {
+		if _, ok := inputsDrifts[requiredApprover]; ok {
+			merged.inputsDriftApprovers = append(merged.inputsDriftApprovers, requiredApprover)
+		}
+		if veto, ok := vetoes[requiredApprover]; ok {
+			merged.vetoingApprovers = append(merged.vetoingApprovers, requiredApprover)
+			merged.approverDetails[requiredApprover] = veto
+		}
+		if nbNotarized[requiredApprover] < len(results) {
+			merged.missingApprovers = append(merged.missingApprovers, requiredApprover)
+			continue
+		}
+		merged.notarizedApprovers = append(merged.notarizedApprovers, requiredApprover)
+		merged.approverDetails[requiredApprover] = results[0].approverDetails[requiredApprover]
+	}
+	return &merged
+}
+
+// printResult prints the final verification outcome.
+func printResult(result *notarizationResult) {
+	if len(result.vetoingApprovers) > 0 {
+		logger.Error(fmt.Sprintf(
+			"PR has been vetoed (i.e. notarized as untrusted) by required approver(s) %s.",
+			strings.Join(result.vetoingApprovers, ", ")))
+		return
+	}
+	if !result.success {
+		logger.Warn(fmt.Sprintf(
+			"PR is notarized for %d of %d required approvers, %d are needed:\n"+
+				"   - notarized: %s\n   - missing  : %s\n   - required : %s",
+			len(result.notarizedApprovers), len(result.requiredApprovers), result.minApprovals,
+			strings.Join(result.notarizedApprovers, ","),
+			strings.Join(result.missingApprovers, ","),
+			strings.Join(result.requiredApprovers, ",")))
+		return
+	}
+	if len(result.missingApprovers) > 0 {
+		logSuccess(fmt.Sprintf(
+			"PR is notarized for %d of %d required approvers, which meets the quorum of %d (%s).",
+			len(result.notarizedApprovers), len(result.requiredApprovers), result.minApprovals,
+			strings.Join(result.notarizedApprovers, ", ")))
+		return
+	}
+	logSuccess(fmt.Sprintf(
+		"PR is notarized for all %d required approvers (%s).",
+		len(result.requiredApprovers), strings.Join(result.requiredApprovers, ", ")))
+}
+
+type ApproverResultJSON struct {
+	Approver string `json:"approver"`
+	// Status is the CNIL status of the notarization, MISSING or EXCUSED
+	Status    string     `json:"status"`
+	Signer    string     `json:"signer,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// InputsDrift is true if the notarization has been made with different action inputs
+	InputsDrift bool `json:"inputs_drift,omitempty"`
+}
+
+type NotarizationResultJSON struct {
+	Success            bool                  `json:"success"`
+	Error              string                `json:"error,omitempty"`
+	Repository         string                `json:"repository,omitempty"`
+	ArtifactName       string                `json:"artifact_name"`
+	ArtifactHash       string                `json:"artifact_hash"`
+	LedgerID           string                `json:"ledger_id,omitempty"`
+	CNILServerVersion  string                `json:"cnil_server_version,omitempty"`
+	VCNVersion         string                `json:"vcn_version,omitempty"`
+	KeyRotationCount   int                   `json:"key_rotation_count"`
+	RequiredApprovers  []string              `json:"required_approvers"`
+	NotarizedApprovers []string              `json:"notarized_approvers"`
+	MissingApprovers   []string              `json:"missing_approvers"`
+	ExcusedApprovers   []string              `json:"excused_approvers"`
+	VetoingApprovers   []string              `json:"vetoing_approvers"`
+	MinApprovals       int                   `json:"min_approvals"`
+	Approvers          []*ApproverResultJSON `json:"approvers"`
+	Timestamp          time.Time             `json:"timestamp"`
+	// Mock is true if nothing has actually been notarized nor verified (see CNIL_MOCK_MODE)
+	Mock bool `json:"mock,omitempty"`
+	// Artifacts holds the result of each artifact of an artifacts manifest (if any)
+	Artifacts []*NotarizationResultJSON `json:"artifacts,omitempty"`
+	// InputsDriftApprovers have notarized the PR with different action inputs (see
+	// FINGERPRINT_WORKFLOW_INPUTS)
+	InputsDriftApprovers []string `json:"inputs_drift_approvers"`
+}
+
+// newNotarizationResultJSON creates the JSON representation of the result, with the
+// details of each required approver (excused ones included), sorted by approver.
+func newNotarizationResultJSON(result *notarizationResult) *NotarizationResultJSON {
+	resultJSON := &NotarizationResultJSON{
+		Success:            result.success,
+		Repository:         result.repository,
+		ArtifactName:       result.artifactName,
+		ArtifactHash:       result.artifactHash,
+		LedgerID:           result.ledgerID,
+		CNILServerVersion:  result.cnilServerVersion,
+		VCNVersion:         result.vcnVersion,
+		KeyRotationCount:   result.keyRotationCount,
+		RequiredApprovers:  append([]string{}, result.requiredApprovers...),
+		NotarizedApprovers: append([]string{}, result.notarizedApprovers...),
+		MissingApprovers:   append([]string{}, result.missingApprovers...),
+		ExcusedApprovers:   append([]string{}, result.excusedApprovers...),
+		VetoingApprovers:   append([]string{}, result.vetoingApprovers...),
+		MinApprovals:       result.minApprovals,
+		Approvers:          []*ApproverResultJSON{},
+		Timestamp:          result.timestamp,
+		Mock:               result.mock,
+	}
+	resultJSON.InputsDriftApprovers = append([]string{}, result.inputsDriftApprovers...)
+	approvers := append(append([]string{}, result.requiredApprovers...), result.excusedApprovers...)
+	sort.Strings(approvers)
+	excused := make(map[string]struct{}, len(result.excusedApprovers))
+	for _, excusedApprover := range result.excusedApprovers {
+		excused[excusedApprover] = struct{}{}
+	}
+	inputsDrifts := make(map[string]struct{}, len(result.inputsDriftApprovers))
+	for _, inputsDriftApprover := range result.inputsDriftApprovers {
+		inputsDrifts[inputsDriftApprover] = struct{}{}
+	}
+	for _, approver := range approvers {
+		approverResult := &ApproverResultJSON{Approver: approver, Status: "MISSING"}
+		if _, ok := excused[approver]; ok {
+			approverResult.Status = "EXCUSED"
+		} else if cnilArtifact := result.approverDetails[approver]; cnilArtifact != nil {
+			timestamp := cnilArtifact.Timestamp.UTC()
+			approverResult.Status = strings.ToUpper(cnilArtifact.Status.String())
+			approverResult.Signer = cnilArtifact.Signer
+			approverResult.Timestamp = &timestamp
+			_, approverResult.InputsDrift = inputsDrifts[approver]
+		}
+		resultJSON.Approvers = append(resultJSON.Approvers, approverResult)
+	}
+	for _, artifactResult := range result.artifacts {
+		resultJSON.Artifacts = append(resultJSON.Artifacts, newNotarizationResultJSON(artifactResult))
+	}
+	return resultJSON
+}
+
+// writeGitHubOutputs appends the result to the GitHub Actions output file, for the next
+// steps of the workflow. A mock result is never approved.
+func writeGitHubOutputs(path string, result *notarizationResult) error {
+	outputs := fmt.Sprintf(
+		"notarized_count=%d\nrequired_count=%d\nall_approved=%t\nnotarized_approvers=%s\nartifact_hash=%s\nmock=%t\n"+
+			"inputs_drift_approvers=%s\n",
+		len(result.notarizedApprovers), len(result.requiredApprovers), result.success && !result.mock,
+		strings.Join(result.notarizedApprovers, ","), result.artifactHash, result.mock,
+		strings.Join(result.inputsDriftApprovers, ","))
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening GitHub output file %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(outputs); err != nil {
+		return fmt.Errorf("error writing GitHub output file %s: %v", path, err)
+	}
+	return nil
+}
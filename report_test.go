@@ -0,0 +1,185 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+	vcnMeta "github.com/vchain-us/vcn/pkg/meta"
+)
+
+func TestNewNotarizationResult(t *testing.T) {
+	required := []string{"alice", "bob", "carol"}
+	tests := []struct {
+		name             string
+		notarized        []string
+		excused          []string
+		minApprovals     int
+		wantSuccess      bool
+		wantMinApprovals int
+		wantRequired     []string
+		wantMissing      []string
+	}{
+		{
+			name:             "all required approvers by default",
+			notarized:        []string{"alice", "bob", "carol"},
+			wantSuccess:      true,
+			wantMinApprovals: 3,
+			wantRequired:     required,
+		},
+		{
+			name:             "one missing without quorum",
+			notarized:        []string{"alice", "carol"},
+			wantMinApprovals: 3,
+			wantRequired:     required,
+			wantMissing:      []string{"bob"},
+		},
+		{
+			name:             "quorum met",
+			notarized:        []string{"alice", "carol"},
+			minApprovals:     2,
+			wantSuccess:      true,
+			wantMinApprovals: 2,
+			wantRequired:     required,
+			wantMissing:      []string{"bob"},
+		},
+		{
+			name:             "quorum not met",
+			notarized:        []string{"carol"},
+			minApprovals:     2,
+			wantMinApprovals: 2,
+			wantRequired:     required,
+			wantMissing:      []string{"alice", "bob"},
+		},
+		{
+			name:             "excused approvers are not required",
+			notarized:        []string{"alice", "carol"},
+			excused:          []string{"bob"},
+			wantSuccess:      true,
+			wantMinApprovals: 2,
+			wantRequired:     []string{"alice", "carol"},
+		},
+		{
+			name:             "quorum capped to the approvers which are not excused",
+			notarized:        []string{"alice"},
+			excused:          []string{"bob", "carol"},
+			minApprovals:     3,
+			wantSuccess:      true,
+			wantMinApprovals: 1,
+			wantRequired:     []string{"alice"},
+		},
+		{
+			name:             "never successful without any notarization",
+			excused:          []string{"alice", "bob", "carol"},
+			minApprovals:     2,
+			wantMinApprovals: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newNotarizationResult(
+				&vcnAPI.Artifact{Name: "pr", Hash: "a1"}, required, tt.notarized, tt.excused, nil, tt.minApprovals)
+			if got.success != tt.wantSuccess {
+				t.Errorf("success = %t, want %t", got.success, tt.wantSuccess)
+			}
+			if got.minApprovals != tt.wantMinApprovals {
+				t.Errorf("minApprovals = %d, want %d", got.minApprovals, tt.wantMinApprovals)
+			}
+			if !reflect.DeepEqual(got.requiredApprovers, tt.wantRequired) {
+				t.Errorf("requiredApprovers = %v, want %v", got.requiredApprovers, tt.wantRequired)
+			}
+			if !reflect.DeepEqual(got.missingApprovers, tt.wantMissing) {
+				t.Errorf("missingApprovers = %v, want %v", got.missingApprovers, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestMergeNotarizationResults(t *testing.T) {
+	trusted := &vcnAPI.LcArtifact{Signer: "alice", Status: vcnMeta.StatusTrusted}
+	untrusted := &vcnAPI.LcArtifact{Signer: "bob", Status: vcnMeta.StatusUntrusted}
+	required := []string{"alice", "bob"}
+	newResult := func(hash string, notarized []string, details map[string]*vcnAPI.LcArtifact) *notarizationResult {
+		return newNotarizationResult(
+			&vcnAPI.Artifact{Name: "artifact-" + hash, Hash: hash}, required, notarized, nil, details, 0)
+	}
+
+	tests := []struct {
+		name          string
+		results       []*notarizationResult
+		wantHash      string
+		wantNotarized []string
+		wantMissing   []string
+		wantVetoing   []string
+		wantSuccess   bool
+	}{
+		{
+			name: "all artifacts notarized by all approvers",
+			results: []*notarizationResult{
+				newResult("a1", required, map[string]*vcnAPI.LcArtifact{"alice": trusted, "bob": trusted}),
+				newResult("b2", required, map[string]*vcnAPI.LcArtifact{"alice": trusted, "bob": trusted}),
+			},
+			wantHash:      "a1,b2",
+			wantNotarized: []string{"alice", "bob"},
+			wantSuccess:   true,
+		},
+		{
+			name: "approver missing on one artifact",
+			results: []*notarizationResult{
+				newResult("a1", required, map[string]*vcnAPI.LcArtifact{"alice": trusted, "bob": trusted}),
+				newResult("b2", []string{"alice"}, map[string]*vcnAPI.LcArtifact{"alice": trusted}),
+			},
+			wantHash:      "a1,b2",
+			wantNotarized: []string{"alice"},
+			wantMissing:   []string{"bob"},
+		},
+		{
+			name: "approver vetoing one artifact",
+			results: func() []*notarizationResult {
+				vetoed := newResult("b2", []string{"alice"}, map[string]*vcnAPI.LcArtifact{"alice": trusted, "bob": untrusted})
+				vetoed.vetoingApprovers = []string{"bob"}
+				vetoed.success = false
+				return []*notarizationResult{
+					newResult("a1", required, map[string]*vcnAPI.LcArtifact{"alice": trusted, "bob": trusted}),
+					vetoed,
+				}
+			}(),
+			wantHash:      "a1,b2",
+			wantNotarized: []string{"alice"},
+			wantMissing:   []string{"bob"},
+			wantVetoing:   []string{"bob"},
+		},
+		{
+			name: "single artifact",
+			results: []*notarizationResult{
+				newResult("a1", []string{"alice"}, map[string]*vcnAPI.LcArtifact{"alice": trusted}),
+			},
+			wantHash:      "a1",
+			wantNotarized: []string{"alice"},
+			wantMissing:   []string{"bob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeNotarizationResults(tt.results)
+			if got.artifactHash != tt.wantHash {
+				t.Errorf("artifactHash = %s, want %s", got.artifactHash, tt.wantHash)
+			}
+			if !reflect.DeepEqual(got.notarizedApprovers, tt.wantNotarized) {
+				t.Errorf("notarizedApprovers = %v, want %v", got.notarizedApprovers, tt.wantNotarized)
+			}
+			if !reflect.DeepEqual(got.missingApprovers, tt.wantMissing) {
+				t.Errorf("missingApprovers = %v, want %v", got.missingApprovers, tt.wantMissing)
+			}
+			if !reflect.DeepEqual(got.vetoingApprovers, tt.wantVetoing) {
+				t.Errorf("vetoingApprovers = %v, want %v", got.vetoingApprovers, tt.wantVetoing)
+			}
+			if got.success != tt.wantSuccess {
+				t.Errorf("success = %t, want %t", got.success, tt.wantSuccess)
+			}
+			if len(tt.results) > 1 && len(newNotarizationResultJSON(got).Artifacts) != len(tt.results) {
+				t.Errorf("JSON result does not hold the %d artifact results", len(tt.results))
+			}
+		})
+	}
+}
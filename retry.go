@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Default retry parameters of the CNIL REST API calls
+const (
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = 30 * time.Second
+	defaultRetryJitter    = 0.2
+)
+
+// retryPolicy defines how sendHTTPRequest retries the requests failing with a transient
+// error: the delay doubles after each retry, up to maxDelay, plus a random jitter of up to
+// jitterFactor times the delay. A nil policy means no retry.
+type retryPolicy struct {
+	maxRetries   uint64
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	jitterFactor float64
+}
+
+// newRetryPolicyFromEnv creates the retry policy of the CNIL REST API calls from the
+// ACTION_RETRY_MAX, ACTION_RETRY_BASE_DELAY and ACTION_RETRY_MAX_DELAY env vars (no retry
+// by default).
+func newRetryPolicyFromEnv() (*retryPolicy, error) {
+	policy := &retryPolicy{
+		maxRetries:   getEnvUint("ACTION_RETRY_MAX", 0),
+		baseDelay:    getEnvDuration("ACTION_RETRY_BASE_DELAY", defaultRetryBaseDelay),
+		maxDelay:     getEnvDuration("ACTION_RETRY_MAX_DELAY", defaultRetryMaxDelay),
+		jitterFactor: defaultRetryJitter,
+	}
+	if policy.maxRetries == 0 {
+		return nil, nil
+	}
+	if policy.baseDelay <= 0 || policy.maxDelay < policy.baseDelay {
+		return nil, fmt.Errorf(
+			"ACTION_RETRY_BASE_DELAY (%s) must be positive and must not exceed ACTION_RETRY_MAX_DELAY (%s)",
+			policy.baseDelay, policy.maxDelay)
+	}
+	return policy, nil
+}
+
+// delay returns the delay before the specified retry (starting from 0).
+func (p *retryPolicy) delay(retry uint64) time.Duration {
+	delay := p.maxDelay
+	if retry < 32 && p.baseDelay<<retry < p.maxDelay && p.baseDelay<<retry > 0 {
+		delay = p.baseDelay << retry
+	}
+	jitter, err := randomDuration(time.Duration(float64(delay) * p.jitterFactor))
+	if err != nil {
+		// the jitter only avoids synchronized retries, it is not worth failing for
+		return delay
+	}
+	return delay + jitter
+}
+
+// isIdempotentHTTPMethod returns true if sending the request of the specified method more
+// than once has the same effect as sending it once.
+func isIdempotentHTTPMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// isTransientHTTPError returns true if the request error is worth retrying: refused
+// connections and rate limiting, which prove that the request has not been processed, and,
+// for the idempotent methods only, network timeouts and temporary server errors (a
+// non-idempotent request, e.g. the creation of an API key, might have been processed
+// anyway).
+func isTransientHTTPError(method string, err error) bool {
+	var statusErr *unexpectedStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.statusCode {
+		case http.StatusTooManyRequests:
+			return true
+		case http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return isIdempotentHTTPMethod(method)
+		}
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return isIdempotentHTTPMethod(method)
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
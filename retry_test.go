@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// timeoutError is a network error which timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransientHTTPError(t *testing.T) {
+	statusError := func(statusCode int) error {
+		return fmt.Errorf("wrapped: %w", &unexpectedStatusError{
+			method: http.MethodGet, url: "https://cnil", statusCode: statusCode, status: http.StatusText(statusCode)})
+	}
+	connectionRefused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		want   bool
+	}{
+		{"rate limited GET", http.MethodGet, statusError(http.StatusTooManyRequests), true},
+		{"rate limited POST", http.MethodPost, statusError(http.StatusTooManyRequests), true},
+		{"unavailable GET", http.MethodGet, statusError(http.StatusServiceUnavailable), true},
+		{"bad gateway PUT", http.MethodPut, statusError(http.StatusBadGateway), true},
+		{"internal error DELETE", http.MethodDelete, statusError(http.StatusInternalServerError), true},
+		{"unavailable POST", http.MethodPost, statusError(http.StatusServiceUnavailable), false},
+		{"gateway timeout PATCH", http.MethodPatch, statusError(http.StatusGatewayTimeout), false},
+		{"not found GET", http.MethodGet, statusError(http.StatusNotFound), false},
+		{"unauthorized GET", http.MethodGet, statusError(http.StatusUnauthorized), false},
+		{"timeout GET", http.MethodGet, &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, true},
+		{"timeout POST", http.MethodPost, &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, false},
+		{"connection refused POST", http.MethodPost, connectionRefused, true},
+		{"connection refused GET", http.MethodGet, connectionRefused, true},
+		{"other error", http.MethodGet, errors.New("x509: certificate signed by unknown authority"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientHTTPError(tt.method, tt.err); got != tt.want {
+				t.Errorf("isTransientHTTPError(%s, %v) = %t, want %t", tt.method, tt.err, got, tt.want)
+			}
+		})
+	}
+}
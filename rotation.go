@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// defaultMaxRotationsPerRun is the default number of rotations of the same API key in a
+// run above which a warning is emitted (see the MAX_ROTATIONS_PER_RUN env var).
+const defaultMaxRotationsPerRun = 1
+
+// keyRotationCounter counts the API keys rotations per signer ID. The pipelined API key
+// mode uses it concurrently.
+type keyRotationCounter struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+func newKeyRotationCounter() *keyRotationCounter {
+	return &keyRotationCounter{counts: make(map[string]int)}
+}
+
+// add counts a rotation of the API key of the signer. It does nothing on a nil counter.
+func (c *keyRotationCounter) add(signerID string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.counts[signerID]++
+}
+
+// total returns the number of rotations of all API keys.
+func (c *keyRotationCounter) total() int {
+	if c == nil {
+		return 0
+	}
+	c.Lock()
+	defer c.Unlock()
+	total := 0
+	for _, count := range c.counts {
+		total += count
+	}
+	return total
+}
+
+// exceeding returns the sorted signer IDs whose API key has been rotated more than max times.
+func (c *keyRotationCounter) exceeding(max int) []string {
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	var signerIDs []string
+	for signerID, count := range c.counts {
+		if count > max {
+			signerIDs = append(signerIDs, signerID)
+		}
+	}
+	sort.Strings(signerIDs)
+	return signerIDs
+}
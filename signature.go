@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+const responseSignatureHeader = "X-CNIL-Response-Signature"
+
+// cnilResponseSigningKey is the PEM-encoded public key the CNIL REST API responses must be
+// signed with (see the CNIL_RESPONSE_SIGNING_PUBLIC_KEY env var), no signature is
+// required if empty.
+var cnilResponseSigningKey []byte
+
+// verifyResponseSignature verifies the base64-encoded signature of the SHA256 hash of the
+// response body in the X-CNIL-Response-Signature header with the PEM-encoded RSA
+// (PKCS #1 v1.5) or ECDSA public key. The response body is left unread.
+func verifyResponseSignature(response *http.Response, publicKey []byte) error {
+	signatureB64 := response.Header.Get(responseSignatureHeader)
+	if len(signatureB64) == 0 {
+		return fmt.Errorf("the response has no %s header", responseSignatureHeader)
+	}
+	signature, err := base64.StdEncoding.DecodeString(signatureB64)
+	if err != nil {
+		return fmt.Errorf("error base64-decoding the %s header: %v", responseSignatureHeader, err)
+	}
+
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return errors.New("the response signing public key is not PEM-encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("error parsing the response signing public key: %v", err)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return fmt.Errorf("error reading response body: %v", err)
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	hash := sha256.Sum256(body)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+			return fmt.Errorf("invalid response signature: %v", err)
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash[:], signature) {
+			return errors.New("invalid response signature")
+		}
+	default:
+		return fmt.Errorf("unsupported response signing public key type %T: expected RSA or ECDSA", key)
+	}
+	return nil
+}
+
+// cnilResponseSignatureTransport verifies the signature of all CNIL REST API responses.
+type cnilResponseSignatureTransport struct {
+	publicKey []byte
+	base      http.RoundTripper
+}
+
+func (t *cnilResponseSignatureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyResponseSignature(response, t.publicKey); err != nil {
+		response.Body.Close()
+		return nil, fmt.Errorf("CNIL response to %s %s may not be authentic: %v", req.Method, req.URL, err)
+	}
+	return response, nil
+}
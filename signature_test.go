@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVerifyResponseSignature(t *testing.T) {
+	body := `{"key":"value"}`
+	hash := sha256.Sum256([]byte(body))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSignature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaSignature, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPEM := func(key interface{}) []byte {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		publicKey []byte
+		wantErr   string
+	}{
+		{
+			name:      "valid RSA signature",
+			body:      body,
+			signature: base64.StdEncoding.EncodeToString(rsaSignature),
+			publicKey: publicKeyPEM(&rsaKey.PublicKey),
+		},
+		{
+			name:      "valid ECDSA signature",
+			body:      body,
+			signature: base64.StdEncoding.EncodeToString(ecdsaSignature),
+			publicKey: publicKeyPEM(&ecdsaKey.PublicKey),
+		},
+		{
+			name:      "tampered body with RSA",
+			body:      `{"key":"other"}`,
+			signature: base64.StdEncoding.EncodeToString(rsaSignature),
+			publicKey: publicKeyPEM(&rsaKey.PublicKey),
+			wantErr:   "invalid response signature",
+		},
+		{
+			name:      "tampered body with ECDSA",
+			body:      `{"key":"other"}`,
+			signature: base64.StdEncoding.EncodeToString(ecdsaSignature),
+			publicKey: publicKeyPEM(&ecdsaKey.PublicKey),
+			wantErr:   "invalid response signature",
+		},
+		{
+			name:      "signature of another key",
+			body:      body,
+			signature: base64.StdEncoding.EncodeToString(rsaSignature),
+			publicKey: publicKeyPEM(&ecdsaKey.PublicKey),
+			wantErr:   "invalid response signature",
+		},
+		{
+			name:      "missing signature",
+			body:      body,
+			publicKey: publicKeyPEM(&rsaKey.PublicKey),
+			wantErr:   "has no X-CNIL-Response-Signature header",
+		},
+		{
+			name:      "signature not base64-encoded",
+			body:      body,
+			signature: "not base64!",
+			publicKey: publicKeyPEM(&rsaKey.PublicKey),
+			wantErr:   "error base64-decoding",
+		},
+		{
+			name:      "public key not PEM-encoded",
+			body:      body,
+			signature: base64.StdEncoding.EncodeToString(rsaSignature),
+			publicKey: []byte("not a key"),
+			wantErr:   "is not PEM-encoded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := &http.Response{
+				Header: http.Header{},
+				Body:   ioutil.NopCloser(strings.NewReader(tt.body)),
+			}
+			if len(tt.signature) > 0 {
+				response.Header.Set(responseSignatureHeader, tt.signature)
+			}
+			err := verifyResponseSignature(response, tt.publicKey)
+			switch {
+			case len(tt.wantErr) == 0 && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case len(tt.wantErr) > 0 && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("got error %v, want error containing %q", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// the body is left unread for the caller
+			if got, err := ioutil.ReadAll(response.Body); err != nil || string(got) != tt.body {
+				t.Errorf("response body = %q (%v), want %q", got, err, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	sigstoreBundle "github.com/sigstore/sigstore-go/pkg/bundle"
+	sigstoreRoot "github.com/sigstore/sigstore-go/pkg/root"
+	sigstoreVerify "github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// sigstoreTrustedRoot returns the trusted root of the Sigstore instance which issued the
+// bundles: the one of the SIGSTORE_TRUSTED_ROOT_PATH file (if specified), e.g. for a private
+// Sigstore deployment, or else the one of the Sigstore public-good instance, fetched with
+// TUF.
+func sigstoreTrustedRoot() (sigstoreRoot.TrustedMaterial, error) {
+	if path := strings.TrimSpace(os.Getenv("SIGSTORE_TRUSTED_ROOT_PATH")); len(path) > 0 {
+		trustedRoot, err := sigstoreRoot.NewTrustedRootFromPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Sigstore trusted root %s: %v", path, err)
+		}
+		return trustedRoot, nil
+	}
+	trustedRoot, err := sigstoreRoot.FetchTrustedRoot()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching the Sigstore public-good trusted root: %v", err)
+	}
+	return trustedRoot, nil
+}
+
+// sigstoreIdentityPolicy returns the policy of the identity the bundle certificates must
+// have been issued to, from the SIGSTORE_CERTIFICATE_OIDC_ISSUER and
+// SIGSTORE_CERTIFICATE_IDENTITY_REGEXP env vars, which are both required: any certificate
+// issued by Fulcio would be accepted otherwise.
+func sigstoreIdentityPolicy() (sigstoreVerify.PolicyOption, error) {
+	issuer := strings.TrimSpace(os.Getenv("SIGSTORE_CERTIFICATE_OIDC_ISSUER"))
+	identityRegexp := strings.TrimSpace(os.Getenv("SIGSTORE_CERTIFICATE_IDENTITY_REGEXP"))
+	if len(issuer) == 0 || len(identityRegexp) == 0 {
+		return nil, errors.New("SIGSTORE_CERTIFICATE_OIDC_ISSUER and SIGSTORE_CERTIFICATE_IDENTITY_REGEXP " +
+			"are required to verify the identity of the Sigstore bundle certificate")
+	}
+	identity, err := sigstoreVerify.NewShortCertificateIdentity(issuer, "", "", identityRegexp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sigstore certificate identity: %v", err)
+	}
+	return sigstoreVerify.WithCertificateIdentity(identity), nil
+}
+
+// verifyWithSigstore verifies the Sigstore bundle of the artifact with the specified
+// (hex-encoded SHA256) hash against the Sigstore trusted root (see sigstoreTrustedRoot): the
+// certificate must chain to the Fulcio roots and be issued to the expected identity (see
+// sigstoreIdentityPolicy), with a signed certificate timestamp, and the signature of the
+// artifact must be recorded in the Rekor transparency log, with a valid signed entry
+// timestamp and inclusion proof.
+func verifyWithSigstore(bundleJSON []byte, artifactHash string) error {
+	b := &sigstoreBundle.Bundle{}
+	if err := b.UnmarshalJSON(bundleJSON); err != nil {
+		return fmt.Errorf("error parsing Sigstore bundle: %v", err)
+	}
+	digest, err := hex.DecodeString(artifactHash)
+	if err != nil {
+		return fmt.Errorf("invalid artifact hash %s: %v", artifactHash, err)
+	}
+	identityPolicy, err := sigstoreIdentityPolicy()
+	if err != nil {
+		return err
+	}
+	trustedRoot, err := sigstoreTrustedRoot()
+	if err != nil {
+		return err
+	}
+	verifier, err := sigstoreVerify.NewSignedEntityVerifier(
+		trustedRoot,
+		sigstoreVerify.WithSignedCertificateTimestamps(1),
+		sigstoreVerify.WithTransparencyLog(1),
+		sigstoreVerify.WithIntegratedTimestamps(1),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating Sigstore verifier: %v", err)
+	}
+	policy := sigstoreVerify.NewPolicy(sigstoreVerify.WithArtifactDigest("sha256", digest), identityPolicy)
+	_, err = verifier.Verify(b, policy)
+	return err
+}
+
+// verifyWithSigstoreAny verifies the Sigstore bundle against each of the specified artifact
+// hashes in turn (see verifyWithSigstore), e.g. the ones of an artifacts manifest: it must
+// be the bundle of one of the artifacts.
+func verifyWithSigstoreAny(bundleJSON []byte, artifactHashes []string) error {
+	err := errors.New("no artifact to verify the bundle against")
+	for _, artifactHash := range artifactHashes {
+		if err = verifyWithSigstore(bundleJSON, artifactHash); err == nil {
+			return nil
+		}
+	}
+	return err
+}
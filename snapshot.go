@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+const approvedFilesManifestName = "approved-files.json"
+
+// fileResourceDescriptor describes a file as an in-toto resource descriptor, the format
+// used for the subjects of Sigstore attestations.
+type fileResourceDescriptor struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// writeApprovedFilesManifest writes a manifest with the SHA256 hash of each of the
+// specified files of the repository at repoDir to outputPath and returns it as a VCN
+// artifact, so that it can be notarized.
+func writeApprovedFilesManifest(repoDir string, files []string, outputPath string) (*vcnAPI.Artifact, error) {
+	manifest := make([]fileResourceDescriptor, 0, len(files))
+	for _, file := range files {
+		hash, err := fileSHA256(filepath.Join(repoDir, file))
+		if err != nil {
+			return nil, err
+		}
+		manifest = append(manifest, fileResourceDescriptor{
+			Name:   file,
+			Digest: map[string]string{"sha256": hash},
+		})
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error JSON-marshaling approved files manifest: %v", err)
+	}
+	if err := ioutil.WriteFile(outputPath, manifestJSON, 0644); err != nil {
+		return nil, fmt.Errorf("error writing approved files manifest %s: %v", outputPath, err)
+	}
+	hash := sha256.Sum256(manifestJSON)
+	return &vcnAPI.Artifact{
+		Kind:        "file",
+		Name:        approvedFilesManifestName,
+		Hash:        hex.EncodeToString(hash[:]),
+		Size:        uint64(len(manifestJSON)),
+		ContentType: "application/json",
+	}, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening file %s: %v", path, err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("error reading file %s: %v", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+)
+
+// fetchX509SVID fetches the default x509-SVID of the workload from the SPIFFE Workload
+// API at the specified socket (e.g. unix:///run/spire/sockets/agent.sock), makes sure it
+// chains to the trust bundle of its trust domain, and returns it as a TLS client
+// certificate.
+func fetchX509SVID(endpointSocket string) (*tls.Certificate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+	x509Context, err := workloadapi.FetchX509Context(ctx, workloadapi.WithAddr(endpointSocket))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching x509-SVID from SPIFFE Workload API at %s: %v", endpointSocket, err)
+	}
+	if len(x509Context.SVIDs) == 0 {
+		return nil, errors.New("the SPIFFE Workload API returned no x509-SVID")
+	}
+	svid := x509Context.DefaultSVID()
+	if _, _, err := x509svid.Verify(svid.Certificates, x509Context.Bundles); err != nil {
+		return nil, fmt.Errorf("invalid SPIFFE x509-SVID %s: %v", svid.ID, err)
+	}
+
+	certificate := &tls.Certificate{PrivateKey: svid.PrivateKey, Leaf: svid.Certificates[0]}
+	for _, cert := range svid.Certificates {
+		certificate.Certificate = append(certificate.Certificate, cert.Raw)
+	}
+	return certificate, nil
+}
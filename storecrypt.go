@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// vcnStoreEncryptedFile is the encrypted archive of the VCN local store directory (see the
+// VCN_STORE_ENCRYPTION_KEY env var).
+const vcnStoreEncryptedFile = "./vcn-store.enc"
+
+// parseStoreEncryptionKey parses the hex-encoded AES-256 key of the VCN store encryption.
+func parseStoreEncryptionKey(hexKey string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(hexKey))
+	if err != nil {
+		return nil, fmt.Errorf("error hex-decoding the VCN store encryption key: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the VCN store encryption key must be 32 bytes long (AES-256), got %d bytes", len(key))
+	}
+	return key, nil
+}
+
+func newStoreCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating VCN store cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptStore archives the store directory as tar, encrypts the archive with AES-GCM
+// (prefixed with the random nonce) to the specified file and removes the directory. It does
+// nothing if the directory does not exist.
+func encryptStore(storeDir string, encryptedFile string, key []byte) error {
+	if _, err := os.Stat(storeDir); os.IsNotExist(err) {
+		return nil
+	}
+	var archive bytes.Buffer
+	tarWriter := tar.NewWriter(&archive)
+	err := filepath.Walk(storeDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(storeDir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = tarWriter.Write(content)
+		return err
+	})
+	if err == nil {
+		err = tarWriter.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error archiving VCN store directory %s: %v", storeDir, err)
+	}
+
+	gcm, err := newStoreCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("error generating VCN store encryption nonce: %v", err)
+	}
+	if err := ioutil.WriteFile(encryptedFile, gcm.Seal(nonce, nonce, archive.Bytes(), nil), 0600); err != nil {
+		return fmt.Errorf("error writing encrypted VCN store file %s: %v", encryptedFile, err)
+	}
+	if err := os.RemoveAll(storeDir); err != nil {
+		return fmt.Errorf("error removing VCN local store directory %s: %v", storeDir, err)
+	}
+	return nil
+}
+
+// decryptStore decrypts the file encrypted by encryptStore and extracts the archive to the
+// store directory. It does nothing if the file does not exist.
+func decryptStore(encryptedFile string, storeDir string, key []byte) error {
+	encrypted, err := ioutil.ReadFile(encryptedFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading encrypted VCN store file %s: %v", encryptedFile, err)
+	}
+	gcm, err := newStoreCipher(key)
+	if err != nil {
+		return err
+	}
+	if len(encrypted) < gcm.NonceSize() {
+		return fmt.Errorf("encrypted VCN store file %s is truncated", encryptedFile)
+	}
+	archive, err := gcm.Open(nil, encrypted[:gcm.NonceSize()], encrypted[gcm.NonceSize():], nil)
+	if err != nil {
+		return fmt.Errorf("error decrypting VCN store file %s (wrong key?): %v", encryptedFile, err)
+	}
+
+	tarReader := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading VCN store archive: %v", err)
+		}
+		path := filepath.Join(storeDir, filepath.FromSlash(header.Name))
+		if relPath, err := filepath.Rel(storeDir, path); err != nil || strings.HasPrefix(relPath, "..") {
+			return errors.New("error extracting VCN store archive: invalid path " + header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0700)
+		case tar.TypeReg:
+			var content []byte
+			if content, err = ioutil.ReadAll(tarReader); err == nil {
+				if err = os.MkdirAll(filepath.Dir(path), 0700); err == nil {
+					err = ioutil.WriteFile(path, content, os.FileMode(header.Mode)&os.ModePerm)
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error extracting VCN store archive to %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecryptStore(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	storeDir := filepath.Join(dir, ".vcn")
+	encryptedFile := filepath.Join(dir, "vcn-store.enc")
+	files := map[string]string{
+		"config.json":        `{"users":[]}`,
+		"state/alice/ledger": "state of alice",
+	}
+	writeTestFiles(t, storeDir, files)
+
+	if err := encryptStore(storeDir, encryptedFile, key); err != nil {
+		t.Fatalf("encryptStore: %v", err)
+	}
+	if _, err := os.Stat(storeDir); !os.IsNotExist(err) {
+		t.Errorf("store directory %s has not been removed", storeDir)
+	}
+	encrypted, err := ioutil.ReadFile(encryptedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted, []byte("state of alice")) {
+		t.Errorf("encrypted store file %s holds the plaintext", encryptedFile)
+	}
+
+	wrongKey := append([]byte{}, key...)
+	wrongKey[0] ^= 0xff
+	if err := decryptStore(encryptedFile, storeDir, wrongKey); err == nil ||
+		!strings.Contains(err.Error(), "wrong key?") {
+		t.Errorf("decryptStore with the wrong key: got error %v", err)
+	}
+
+	if err := decryptStore(encryptedFile, storeDir, key); err != nil {
+		t.Fatalf("decryptStore: %v", err)
+	}
+	for path, content := range files {
+		got, err := ioutil.ReadFile(filepath.Join(storeDir, path))
+		if err != nil || string(got) != content {
+			t.Errorf("decrypted %s = %q (%v), want %q", path, got, err, content)
+		}
+	}
+
+	if err := decryptStore(filepath.Join(dir, "missing.enc"), storeDir, key); err != nil {
+		t.Errorf("decryptStore of a missing file: unexpected error %v", err)
+	}
+}
+
+func TestDecryptStorePathTraversal(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	tests := []string{"../evil", "state/../../evil", "state/../../../tmp/evil"}
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			storeDir := filepath.Join(dir, "store", ".vcn")
+			encryptedFile := filepath.Join(dir, "vcn-store.enc")
+
+			var archive bytes.Buffer
+			tarWriter := tar.NewWriter(&archive)
+			content := []byte("evil")
+			if err := tarWriter.WriteHeader(&tar.Header{
+				Name:     name,
+				Typeflag: tar.TypeReg,
+				Mode:     0600,
+				Size:     int64(len(content)),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tarWriter.Write(content); err != nil {
+				t.Fatal(err)
+			}
+			if err := tarWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			gcm, err := newStoreCipher(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nonce := make([]byte, gcm.NonceSize())
+			if err := ioutil.WriteFile(encryptedFile, gcm.Seal(nonce, nonce, archive.Bytes(), nil), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err = decryptStore(encryptedFile, storeDir, key)
+			if err == nil || !strings.Contains(err.Error(), "invalid path") {
+				t.Fatalf("got error %v, want invalid path error", err)
+			}
+			if _, err := os.Stat(filepath.Join(storeDir, name)); !os.IsNotExist(err) {
+				t.Errorf("%s has been extracted outside of the store directory", name)
+			}
+		})
+	}
+}
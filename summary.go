@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// writeStepSummary appends a Markdown table with the verification result of each required
+// approver to the GitHub Actions step summary file.
+func writeStepSummary(path string, result *notarizationResult) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening step summary file %s: %v", path, err)
+	}
+	defer f.Close()
+	summary := resultMarkdown(result) + fmt.Sprintf("\nAPI keys rotated in this run: %d\n", result.keyRotationCount)
+	if _, err := f.WriteString(summary); err != nil {
+		return fmt.Errorf("error writing step summary file %s: %v", path, err)
+	}
+	return nil
+}
+
+// resultMarkdown formats the result as Markdown, with a table holding the verification
+// result of each required approver.
+func resultMarkdown(result *notarizationResult) string {
+	resultJSON := newNotarizationResultJSON(result)
+	var summary strings.Builder
+	summary.WriteString("### CodeNotary PR notarization\n\n")
+	if result.mock {
+		summary.WriteString("> [!WARNING]\n> CNIL mock mode: nothing has actually been notarized nor verified.\n\n")
+	}
+	switch {
+	case len(result.vetoingApprovers) > 0:
+		fmt.Fprintf(&summary, "PR has been vetoed by required approver(s) %s.\n\n",
+			strings.Join(result.vetoingApprovers, ", "))
+	case result.success && len(result.missingApprovers) == 0:
+		fmt.Fprintf(&summary, "PR is notarized for all %d required approvers.\n\n", len(result.requiredApprovers))
+	case result.success:
+		fmt.Fprintf(&summary, "PR is notarized for %d of %d required approvers, which meets the quorum of %d.\n\n",
+			len(result.notarizedApprovers), len(result.requiredApprovers), result.minApprovals)
+	default:
+		fmt.Fprintf(&summary, "PR is notarized for %d of %d required approvers, %d are needed.\n\n",
+			len(result.notarizedApprovers), len(result.requiredApprovers), result.minApprovals)
+	}
+	summary.WriteString("| Approver | Status | Artifact hash | Signer ID | Timestamp |\n")
+	summary.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, approver := range resultJSON.Approvers {
+		emoji := "❌"
+		switch approver.Status {
+		case "TRUSTED":
+			emoji = "✅"
+		case "EXCUSED", "APIKEY_REVOKED", "REVOKED":
+			emoji = "⚠️"
+		}
+		var timestamp string
+		if approver.Timestamp != nil {
+			timestamp = approver.Timestamp.Format(time.RFC3339)
+		}
+		status := approver.Status
+		if approver.InputsDrift {
+			status += " (different action inputs)"
+		}
+		fmt.Fprintf(&summary, "| %s | %s %s | `%s` | %s | %s |\n",
+			approver.Approver, emoji, status, result.artifactHash, approver.Signer, timestamp)
+	}
+	fmt.Fprintf(&summary, "\nVerified at %s (CNIL server version: %s, vcn library version: %s)\n",
+		result.timestamp.Format(time.RFC3339), result.cnilServerVersion, result.vcnVersion)
+	return summary.String()
+}
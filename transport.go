@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// httpTimeout is the timeout of the HTTP clients created by newHTTPClient (see the
+// ACTION_HTTP_TIMEOUT env var).
+var httpTimeout = defaultHTTPTimeout
+
+// httpProxyURL is the proxy of the HTTP clients created by newHTTPClient, overriding the
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY env vars (see the ACTION_HTTP_PROXY env var).
+var httpProxyURL *url.URL
+
+// gRPC message size limits (in bytes) of the CNIL gRPC API calls
+const (
+	defaultGRPCMsgSize = 4 * 1024 * 1024
+	maxGRPCMsgSize     = 64 * 1024 * 1024
+)
+
+// parseTLSMinVersion parses the minimum TLS version ("TLS12" or "TLS13") to be used
+// for the connections to CNIL. Defaults to TLS 1.2.
+func parseTLSMinVersion(version string) (uint16, error) {
+	switch strings.ToUpper(version) {
+	case "", "TLS12":
+		return tls.VersionTLS12, nil
+	case "TLS13":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS min version \"%s\": expected TLS12 or TLS13", version)
+	}
+}
+
+// parseHTTPProxy parses the URL of an HTTP(S) or SOCKS5 proxy.
+func parseHTTPProxy(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP proxy URL \"%s\": %v", proxy, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid HTTP proxy URL \"%s\": expected an http, https or socks5 URL", proxy)
+	}
+	if len(proxyURL.Host) == 0 {
+		return nil, fmt.Errorf("invalid HTTP proxy URL \"%s\": no host", proxy)
+	}
+	return proxyURL, nil
+}
+
+// buildHTTPClient creates an HTTP client with the specified timeout, which connects through
+// the specified proxy, or else through the proxy of the HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+// env vars (if any).
+func buildHTTPClient(timeout time.Duration, proxyURL *url.URL) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// newHTTPClient creates the HTTP client used for the REST API calls.
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	client := buildHTTPClient(httpTimeout, httpProxyURL)
+	client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+	if networkTrace != nil {
+		client.Transport = &harTransport{recorder: networkTrace, base: client.Transport}
+	}
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		client.Transport = &debugTransport{base: client.Transport}
+	}
+	return client
+}
+
+// cnilOrgIDTransport adds the organization ID to all requests to the CNIL REST API, as
+// required by multi-tenant CNIL deployments.
+type cnilOrgIDTransport struct {
+	orgID string
+	base  http.RoundTripper
+}
+
+func (t *cnilOrgIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	query := req.URL.Query()
+	query.Set("org_id", t.orgID)
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("X-Organization-ID", t.orgID)
+	return t.base.RoundTrip(req)
+}
+
+// defaultCNILAPIVersion is the major version of the CNIL REST API the action is built for.
+const defaultCNILAPIVersion = "1"
+
+// cnilAPIVersion is the CNIL REST API version requested by the action (see the
+// CNIL_API_VERSION env var).
+var cnilAPIVersion = defaultCNILAPIVersion
+
+// cnilAPIVersionTransport pins the version of the CNIL REST API in all requests, with both
+// the X-API-Version and the Accept headers, and warns (once) if the server responds with
+// another version, so that a server upgrade cannot silently change the API behavior.
+type cnilAPIVersionTransport struct {
+	version     string
+	base        http.RoundTripper
+	warningOnce sync.Once
+}
+
+func (t *cnilAPIVersionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-API-Version", t.version)
+	req.Header.Set("Accept", fmt.Sprintf("application/vnd.cnil.v%s+json, application/json", t.version))
+	response, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if version := strings.TrimSpace(response.Header.Get("X-API-Version")); len(version) > 0 && version != t.version {
+		t.warningOnce.Do(func() {
+			logger.Warn(fmt.Sprintf(
+				"WARNING: the CNIL REST API responded with API version %s instead of the requested version %s",
+				version, t.version))
+		})
+	}
+	return response, nil
+}
+
+// newCNILHTTPClient creates the HTTP client used for the CNIL REST API calls, sending the
+// API version and the organization ID (if any) with each request and verifying the
+// response signatures (if required).
+func newCNILHTTPClient(tlsConfig *tls.Config, orgID string) *http.Client {
+	client := newHTTPClient(tlsConfig)
+	client.Transport = &cnilAPIVersionTransport{version: cnilAPIVersion, base: client.Transport}
+	if len(orgID) > 0 {
+		client.Transport = &cnilOrgIDTransport{orgID: orgID, base: client.Transport}
+	}
+	if len(cnilResponseSigningKey) > 0 {
+		client.Transport = &cnilResponseSignatureTransport{publicKey: cnilResponseSigningKey, base: client.Transport}
+	}
+	return client
+}
+
+// newCNILUser creates a VCN CNIL user (i.e. client) for the API key in the specified options.
+func newCNILUser(options *vcnOptions) (*vcnAPI.LcUser, error) {
+	vcnCNILUser, err := vcnAPI.NewLcUser(
+		options.cnilAPIKey, "", options.cnilHost, options.cnilPort, "", false, options.noTLS)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing vcn client: %v", err)
+	}
+	if !options.noTLS && options.tlsConfig != nil {
+		// the last transport credentials dial option overrides the ones set by VCN
+		vcnCNILUser.Client.DialOptions = append(
+			vcnCNILUser.Client.DialOptions,
+			grpc.WithTransportCredentials(credentials.NewTLS(options.tlsConfig)))
+	}
+	if options.grpcMaxRecvMsgSize > 0 || options.grpcMaxSendMsgSize > 0 {
+		var callOptions []grpc.CallOption
+		if options.grpcMaxRecvMsgSize > 0 {
+			callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(options.grpcMaxRecvMsgSize))
+		}
+		if options.grpcMaxSendMsgSize > 0 {
+			callOptions = append(callOptions, grpc.MaxCallSendMsgSize(options.grpcMaxSendMsgSize))
+		}
+		vcnCNILUser.Client.DialOptions = append(
+			vcnCNILUser.Client.DialOptions, grpc.WithDefaultCallOptions(callOptions...))
+	}
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		vcnCNILUser.Client.DialOptions = append(
+			vcnCNILUser.Client.DialOptions, grpc.WithChainUnaryInterceptor(debugUnaryInterceptor))
+	}
+	return vcnCNILUser, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type ArtifactSignerResponse struct {
+	Signer string `json:"signer"`
+}
+
+type ArtifactSignersPageResponse struct {
+	Total uint64                    `json:"total"`
+	Items []*ArtifactSignerResponse `json:"items"`
+}
+
+// listArtifactSigners returns the signer IDs of all notarizations of the artifact with the
+// specified hash in the ledger.
+func listArtifactSigners(options *cnilOptions, hash string) ([]string, error) {
+	var signers []string
+	if cnilMockMode {
+		// every mocked API key is assumed to have been used to notarize the artifact
+		mockCNIL.Lock()
+		defer mockCNIL.Unlock()
+		for signerID := range mockCNIL.apiKeys {
+			signers = append(signers, signerID)
+		}
+		return signers, nil
+	}
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/ledgers/%s/artifacts/%s/signers?page=%d&per_page=%d",
+			options.baseURL, options.ledgerID, hash, page, apiKeysPageSize)
+		responsePayload := ArtifactSignersPageResponse{}
+		if err := sendHTTPRequest(
+			options.httpClient,
+			options.retryPolicy,
+			http.MethodGet,
+			url,
+			options.token,
+			http.StatusOK,
+			nil,
+			&responsePayload,
+		); err != nil {
+			return nil, err
+		}
+		for _, item := range responsePayload.Items {
+			signers = append(signers, item.Signer)
+		}
+		if len(responsePayload.Items) == 0 || uint64(len(signers)) >= responsePayload.Total {
+			return signers, nil
+		}
+	}
+}
+
+// foreignSigners returns the signers (without their identity suffix) which are not
+// required approvers.
+func foreignSigners(signers []string, apiKeyPerRequiredApprover map[string]string) []string {
+	foreign := make(map[string]struct{})
+	for _, signer := range signers {
+		signer = strings.TrimSuffix(signer, identitySuffix)
+		if _, ok := apiKeyPerRequiredApprover[signer]; !ok {
+			foreign[signer] = struct{}{}
+		}
+	}
+	result := make([]string, 0, len(foreign))
+	for signer := range foreign {
+		result = append(result, signer)
+	}
+	sort.Strings(result)
+	return result
+}
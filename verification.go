@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+)
+
+// approverVerification is the result of the verification of the PR for a required approver.
+type approverVerification struct {
+	approver string
+	// excused approvers are not verified
+	excused      bool
+	cnilArtifact *vcnAPI.LcArtifact
+	// the local checks of the notarization failed (see validateNotarization), in which case
+	// it counts as missing
+	invalid error
+	err     error
+}
+
+// verificationHooks are the shell commands run before and after the verification of each
+// required approver (optional).
+type verificationHooks struct {
+	pre  string
+	post string
+}
+
+// verifyApprovers verifies the artifact for each of the API keys received on the channel
+// concurrently, one goroutine per required approver, and returns the results sorted by
+// approver. Each received API key is added to apiKeyPerRequiredApprover. The excused
+// approvers are not verified.
+func verifyApprovers(
+	artifact *vcnAPI.Artifact,
+	options *vcnOptions,
+	apiKeys <-chan approverAPIKey,
+	apiKeyPerRequiredApprover map[string]string,
+	excusedApprovers map[string]struct{},
+	hooks verificationHooks,
+) ([]*approverVerification, error) {
+	results := make(chan *approverVerification)
+	collected := make(chan []*approverVerification)
+	go func() {
+		var verifications []*approverVerification
+		for verification := range results {
+			verifications = append(verifications, verification)
+		}
+		collected <- verifications
+	}()
+
+	var wg sync.WaitGroup
+	var apiKeyErr error
+	for apiKey := range apiKeys {
+		if apiKey.err != nil {
+			apiKeyErr = apiKey.err
+			break
+		}
+		apiKeyPerRequiredApprover[apiKey.approver] = apiKey.apiKey
+
+		if _, ok := excusedApprovers[apiKey.approver]; ok {
+			results <- &approverVerification{approver: apiKey.approver, excused: true}
+			continue
+		}
+
+		// each goroutine creates its own vcn client, which is not safe to share
+		approverOptions := *options
+		approverOptions.cnilAPIKey = apiKey.apiKey
+		wg.Add(1)
+		go func(approver string) {
+			defer wg.Done()
+			results <- verifyApprover(artifact, &approverOptions, approver, hooks)
+		}(apiKey.approver)
+	}
+	wg.Wait()
+	close(results)
+	verifications := <-collected
+	if apiKeyErr != nil {
+		return nil, apiKeyErr
+	}
+
+	sort.Slice(verifications, func(i, j int) bool {
+		return verifications[i].approver < verifications[j].approver
+	})
+	return verifications, nil
+}
+
+// verifyApprover verifies the artifact for the required approver, running the verification
+// hooks (if any) with the APPROVER_USERNAME, ARTIFACT_HASH and (post hook only)
+// VERIFY_STATUS env vars. The verification fails if the pre hook fails.
+func verifyApprover(
+	artifact *vcnAPI.Artifact,
+	options *vcnOptions,
+	approver string,
+	hooks verificationHooks,
+) *approverVerification {
+	verification := &approverVerification{approver: approver}
+	env := map[string]string{"APPROVER_USERNAME": approver, "ARTIFACT_HASH": artifact.Hash}
+	if len(hooks.pre) > 0 {
+		if verification.err = runHook("pre-verify hook", hooks.pre, env); verification.err != nil {
+			return verification
+		}
+	}
+	verification.cnilArtifact, verification.err = verify(artifact, options)
+	if verification.err == nil && verification.cnilArtifact != nil {
+		verification.invalid = validateNotarization(verification.cnilArtifact, options)
+	}
+	if len(hooks.post) > 0 {
+		switch {
+		case verification.err != nil:
+			env["VERIFY_STATUS"] = "ERROR"
+		case verification.cnilArtifact == nil:
+			env["VERIFY_STATUS"] = "MISSING"
+		case verification.invalid != nil:
+			env["VERIFY_STATUS"] = "INVALID"
+		default:
+			env["VERIFY_STATUS"] = strings.ToUpper(verification.cnilArtifact.Status.String())
+		}
+		if hookErr := runHook("post-verify hook", hooks.post, env); hookErr != nil {
+			logger.Warn(fmt.Sprintf("   WARNING: %v", hookErr))
+		}
+	}
+	return verification
+}
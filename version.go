@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// MinSupportedCNILVersion is the oldest CNIL server version supported by the action. The
+// action is built for this major version of the CNIL APIs.
+const MinSupportedCNILVersion = "v1.0.0"
+
+const (
+	versionCmd    = "--version"
+	vcnModulePath = "github.com/vchain-us/vcn"
+	vcnRepository = "vchain-us/vcn"
+)
+
+// buildVCNVersion is the version of the vcn library the action is compiled with, set at
+// build time with -ldflags "-X main.buildVCNVersion=<version>".
+var buildVCNVersion string
+
+// vcnLibraryVersion returns the version of the vcn library the action is compiled with,
+// falling back to the module build information if it has not been set at build time.
+func vcnLibraryVersion() string {
+	if len(buildVCNVersion) > 0 {
+		return buildVCNVersion
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range buildInfo.Deps {
+			if dep.Path != vcnModulePath {
+				continue
+			}
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+type CNILVersionResponse struct {
+	Version string `json:"version"`
+}
+
+// checkCNILServerVersion fetches the CNIL server version from GET <serverURL>/version and
+// returns it, or an error if it is older than MinSupportedCNILVersion. A warning is
+// printed if the server is more than one major version ahead of the action.
+func checkCNILServerVersion(client *http.Client, serverURL string) (string, error) {
+	if cnilMockMode {
+		return MinSupportedCNILVersion, nil
+	}
+	url := strings.TrimSuffix(serverURL, "/") + "/version"
+	responsePayload := CNILVersionResponse{}
+	if err := sendHTTPRequest(client, nil, http.MethodGet, url, "", http.StatusOK, nil, &responsePayload); err != nil {
+		return "", err
+	}
+
+	version := strings.TrimSpace(responsePayload.Version)
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if !semver.IsValid(version) {
+		return "", fmt.Errorf("invalid CNIL server version \"%s\"", responsePayload.Version)
+	}
+	if semver.Compare(version, MinSupportedCNILVersion) < 0 {
+		return version, fmt.Errorf("CNIL server version %s is not supported: the minimum supported version is %s",
+			version, MinSupportedCNILVersion)
+	}
+
+	var serverMajor, clientMajor int
+	fmt.Sscanf(semver.Major(version), "v%d", &serverMajor)
+	fmt.Sscanf(semver.Major(MinSupportedCNILVersion), "v%d", &clientMajor)
+	if serverMajor > clientMajor+1 {
+		logger.Warn(fmt.Sprintf(
+			"WARNING: CNIL server version %s is more than one major version ahead of the supported version %s",
+			version, MinSupportedCNILVersion))
+	}
+	return version, nil
+}
+
+type GitHubReleaseResponse struct {
+	TagName string `json:"tag_name"`
+}
+
+// checkVCNLibraryOutdated fetches the latest release of the vcn library from the GitHub
+// releases API (see gitHubAPIURL) and compares it with the version the action is compiled
+// with: it returns an error if the latest release is a major version ahead, and prints a
+// warning if it is more than one minor version ahead. The check is skipped with a warning
+// if either version cannot be determined.
+func checkVCNLibraryOutdated(client *http.Client, token string) error {
+	version := vcnLibraryVersion()
+	if !semver.IsValid(version) {
+		logger.Warn(fmt.Sprintf(
+			"WARNING: the vcn library version \"%s\" is not a valid semantic version, skipping the update check",
+			version))
+		return nil
+	}
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", gitHubAPIURL(), vcnRepository)
+	release := GitHubReleaseResponse{}
+	if err := sendHTTPRequest(client, nil, http.MethodGet, url, token, http.StatusOK, nil, &release); err != nil {
+		logger.Warn(fmt.Sprintf(
+			"WARNING: error getting the latest vcn library release, skipping the update check: %v", err))
+		return nil
+	}
+	latest := strings.TrimSpace(release.TagName)
+	if !strings.HasPrefix(latest, "v") {
+		latest = "v" + latest
+	}
+	if !semver.IsValid(latest) {
+		logger.Warn(fmt.Sprintf(
+			"WARNING: invalid latest vcn library release \"%s\", skipping the update check", release.TagName))
+		return nil
+	}
+
+	var major, minor, latestMajor, latestMinor int
+	fmt.Sscanf(semver.MajorMinor(version), "v%d.%d", &major, &minor)
+	fmt.Sscanf(semver.MajorMinor(latest), "v%d.%d", &latestMajor, &latestMinor)
+	switch {
+	case latestMajor > major:
+		return fmt.Errorf("the vcn library version %s is a major version behind the latest release %s",
+			version, latest)
+	case latestMajor == major && latestMinor > minor+1:
+		logger.Warn(fmt.Sprintf(
+			"WARNING: the vcn library version %s is more than one minor version behind the latest release %s",
+			version, latest))
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type WebhookTriggerReq struct {
+	Hash string `json:"hash"`
+}
+
+// triggerLedgerWebhooks forces the immediate delivery of the CNIL-side webhooks of the
+// ledger for the new notarization of the artifact with the specified hash.
+func triggerLedgerWebhooks(options *cnilOptions, hash string) error {
+	if cnilMockMode {
+		return nil
+	}
+	url := fmt.Sprintf("%s/ledgers/%s/webhooks/trigger", options.baseURL, options.ledgerID)
+	payload := WebhookTriggerReq{Hash: hash}
+	payloadJSON, err := json.Marshal(&payload)
+	if err != nil {
+		return fmt.Errorf(
+			"error JSON-marshaling POST %s request with payload %+v: %v",
+			url, payload, err)
+	}
+	err = sendHTTPRequest(
+		options.httpClient,
+		options.retryPolicy,
+		http.MethodPost,
+		url,
+		options.token,
+		http.StatusAccepted,
+		bytes.NewBuffer(payloadJSON),
+		nil,
+	)
+	var statusErr *unexpectedStatusError
+	if errors.As(err, &statusErr) &&
+		(statusErr.statusCode == http.StatusOK || statusErr.statusCode == http.StatusNoContent) {
+		// some CNIL deployments deliver the webhooks synchronously
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	vcnAPI "github.com/vchain-us/vcn/pkg/api"
+	vcnDockerExtractor "github.com/vchain-us/vcn/pkg/extractor/docker"
+	vcnMeta "github.com/vchain-us/vcn/pkg/meta"
+	vcnURI "github.com/vchain-us/vcn/pkg/uri"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	workflowsDir      = ".github/workflows"
+	dockerUsesPrefix  = "docker://"
+	imageDigestPrefix = "@sha256:"
+)
+
+// ciImageRefs returns the (sorted, deduplicated) Docker image references used by the
+// jobs of the workflow file being run (see currentWorkflowFile) of the repository at
+// repoDir: job containers (both the "container: <image>" and the
+// "container: {image: <image>}" forms), services and "docker://" steps.
+// References set by an expression (e.g. "${{ matrix.image }}") can not be resolved
+// before the run and are skipped.
+func ciImageRefs(repoDir string) ([]string, error) {
+	workflowFile, err := currentWorkflowFile()
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(filepath.Join(repoDir, workflowFile))
+	if err != nil {
+		return nil, fmt.Errorf("error reading workflow file %s: %v", workflowFile, err)
+	}
+	var workflow ciWorkflow
+	if err := yaml.Unmarshal(content, &workflow); err != nil {
+		return nil, fmt.Errorf("error parsing workflow file %s: %v", workflowFile, err)
+	}
+
+	refs := make(map[string]struct{})
+	addRef := func(ref string) {
+		if len(ref) > 0 && !strings.Contains(ref, "${{") {
+			refs[ref] = struct{}{}
+		}
+	}
+	for _, job := range workflow.Jobs {
+		switch container := job.Container.(type) {
+		case string:
+			addRef(container)
+		case map[interface{}]interface{}:
+			if image, ok := container["image"].(string); ok {
+				addRef(image)
+			}
+		}
+		for _, service := range job.Services {
+			addRef(service.Image)
+		}
+		for _, step := range job.Steps {
+			if strings.HasPrefix(step.Uses, dockerUsesPrefix) {
+				addRef(strings.TrimPrefix(step.Uses, dockerUsesPrefix))
+			}
+		}
+	}
+
+	var sortedRefs []string
+	for ref := range refs {
+		sortedRefs = append(sortedRefs, ref)
+	}
+	sort.Strings(sortedRefs)
+	return sortedRefs, nil
+}
+
+// ciWorkflow holds the parts of a workflow file which reference Docker images.
+type ciWorkflow struct {
+	Jobs map[string]struct {
+		// Container is either the image or a map holding it under "image".
+		Container interface{} `yaml:"container"`
+		Services  map[string]struct {
+			Image string `yaml:"image"`
+		} `yaml:"services"`
+		Steps []struct {
+			Uses string `yaml:"uses"`
+		} `yaml:"steps"`
+	} `yaml:"jobs"`
+}
+
+// vcnArtifactFromDockerImage pulls the specified image and creates its artifact with the
+// vcn docker extractor, i.e. hashed by image ID as "vcn notarize docker://<image>" does (the
+// manifest digest of the reference is not the notarized hash).
+func vcnArtifactFromDockerImage(imageRef string) (*vcnAPI.Artifact, error) {
+	if output, err := exec.Command("docker", "pull", "--quiet", imageRef).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("error pulling image %s: %v: %s", imageRef, err, strings.TrimSpace(string(output)))
+	}
+	imageURI, err := vcnURI.Parse(vcnDockerExtractor.Scheme + "://" + imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing image reference %s: %v", imageRef, err)
+	}
+	vcnArtifact, err := vcnDockerExtractor.Artifact(imageURI)
+	if err != nil {
+		return nil, fmt.Errorf("error creating artifact: %v", err)
+	}
+	if len(vcnArtifact) == 0 {
+		return nil, fmt.Errorf("no artifact created from image %s", imageRef)
+	}
+	return vcnArtifact[0], nil
+}
+
+// verifyCIImages verifies each of the specified image references against the ledger and
+// returns the ones which are not trusted by at least one of the required approvers.
+// Only digest-pinned references (e.g. alpine@sha256:...) are verified, as the image of any
+// other reference can change after the verification, so they are returned as unverified.
+func verifyCIImages(
+	imageRefs []string,
+	apiKeyPerRequiredApprover map[string]string,
+	options *vcnOptions,
+) ([]string, error) {
+	var unverifiedImages []string
+	for _, imageRef := range imageRefs {
+		if !strings.Contains(imageRef, imageDigestPrefix) {
+			unverifiedImages = append(unverifiedImages, imageRef+" (not pinned to a digest)")
+			continue
+		}
+		artifact, err := vcnArtifactFromDockerImage(imageRef)
+		if err != nil {
+			return nil, err
+		}
+
+		trusted := false
+		for requiredApprover, apiKey := range apiKeyPerRequiredApprover {
+			imageOptions := *options
+			imageOptions.cnilAPIKey = apiKey
+			cnilArtifact, err := verify(artifact, &imageOptions)
+			if err != nil {
+				return nil, fmt.Errorf("error verifying image %s for approver %s: %v",
+					imageRef, requiredApprover, err)
+			}
+			if cnilArtifact != nil && cnilArtifact.Status == vcnMeta.StatusTrusted &&
+				validateNotarization(cnilArtifact, &imageOptions) == nil {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			unverifiedImages = append(unverifiedImages, imageRef)
+		}
+	}
+	return unverifiedImages, nil
+}
+
+// currentWorkflowFile returns the path (relative to the repository root) of the workflow
+// file being run, as derived from GITHUB_WORKFLOW_REF (e.g.
+// owner/repo/.github/workflows/file.yml@refs/heads/main) or, if not available, from
+// GITHUB_WORKFLOW (which is the workflow path only for workflows without a name).
+func currentWorkflowFile() (string, error) {
+	if workflowRef := os.Getenv("GITHUB_WORKFLOW_REF"); len(workflowRef) > 0 {
+		workflowPath := strings.SplitN(workflowRef, "@", 2)[0]
+		if i := strings.Index(workflowPath, workflowsDir+"/"); i >= 0 {
+			return workflowPath[i:], nil
+		}
+		return "", fmt.Errorf("unexpected GITHUB_WORKFLOW_REF value \"%s\"", workflowRef)
+	}
+	if workflow := os.Getenv("GITHUB_WORKFLOW"); strings.HasPrefix(workflow, workflowsDir+"/") {
+		return workflow, nil
+	}
+	return "", errors.New(
+		"the current workflow file can not be determined from GITHUB_WORKFLOW_REF or GITHUB_WORKFLOW")
+}
+
+// vcnArtifactFromWorkflowFile creates a VCN artifact from the SHA256 of the specified
+// workflow file (relative to the repository root).
+func vcnArtifactFromWorkflowFile(repoDir string, workflowFile string) (*vcnAPI.Artifact, error) {
+	content, err := ioutil.ReadFile(filepath.Join(repoDir, workflowFile))
+	if err != nil {
+		return nil, fmt.Errorf("error reading workflow file %s: %v", workflowFile, err)
+	}
+	hash := sha256.Sum256(content)
+	return &vcnAPI.Artifact{
+		Kind:        "file",
+		Name:        workflowFile,
+		Hash:        hex.EncodeToString(hash[:]),
+		Size:        uint64(len(content)),
+		ContentType: "text/yaml",
+	}, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCIImageRefs(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "job containers, services and docker steps",
+			files: map[string]string{
+				".github/workflows/build.yml": `
+jobs:
+  build:
+    container:
+      image: golang:1.21@sha256:aaaa
+    services:
+      db:
+        image: postgres:16
+    steps:
+      - uses: actions/checkout@v4
+      - uses: docker://alpine:3.19
+  lint:
+    container: node:20
+`,
+			},
+			want: []string{"alpine:3.19", "golang:1.21@sha256:aaaa", "node:20", "postgres:16"},
+		},
+		{
+			name: "images deduplicated and other workflow files ignored",
+			files: map[string]string{
+				".github/workflows/build.yml": "jobs:\n  a:\n    container: alpine:3.19\n" +
+					"  b:\n    steps:\n      - uses: docker://alpine:3.19\n",
+				".github/workflows/other.yaml": "jobs:\n  c:\n    container: ignored:latest\n",
+			},
+			want: []string{"alpine:3.19"},
+		},
+		{
+			name: "image keys outside of containers and services ignored",
+			files: map[string]string{
+				".github/workflows/build.yml": `
+jobs:
+  build:
+    strategy:
+      matrix:
+        image: [ignored:1, ignored:2]
+    steps:
+      - uses: ./.github/actions/build
+        with:
+          image: ignored:latest
+          container: ignored:latest
+`,
+			},
+			want: nil,
+		},
+		{
+			name: "expressions skipped",
+			files: map[string]string{
+				".github/workflows/build.yml": `
+jobs:
+  build:
+    container: ${{ matrix.image }}
+    services:
+      db:
+        image: postgres:${{ matrix.version }}
+    steps:
+      - uses: docker://alpine:3.19
+`,
+			},
+			want: []string{"alpine:3.19"},
+		},
+		{
+			name: "no docker image",
+			files: map[string]string{
+				".github/workflows/build.yml": "jobs:\n  test:\n    runs-on: ubuntu-latest\n",
+			},
+			want: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("GITHUB_WORKFLOW_REF", "owner/repo/.github/workflows/build.yml@refs/heads/main")
+			repoDir := t.TempDir()
+			writeTestFiles(t, repoDir, test.files)
+			got, err := ciImageRefs(repoDir)
+			if err != nil {
+				t.Fatalf("ciImageRefs: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ciImageRefs() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCurrentWorkflowFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		workflowRef string
+		workflow    string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "from GITHUB_WORKFLOW_REF",
+			workflowRef: "owner/repo/.github/workflows/notarize.yml@refs/heads/main",
+			workflow:    "Notarize",
+			want:        ".github/workflows/notarize.yml",
+		},
+		{
+			name:     "from GITHUB_WORKFLOW of an unnamed workflow",
+			workflow: ".github/workflows/notarize.yml",
+			want:     ".github/workflows/notarize.yml",
+		},
+		{
+			name:        "unexpected GITHUB_WORKFLOW_REF",
+			workflowRef: "owner/repo/notarize.yml@refs/heads/main",
+			wantErr:     true,
+		},
+		{
+			name:     "named workflow without GITHUB_WORKFLOW_REF",
+			workflow: "Notarize",
+			wantErr:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("GITHUB_WORKFLOW_REF", test.workflowRef)
+			t.Setenv("GITHUB_WORKFLOW", test.workflow)
+			got, err := currentWorkflowFile()
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("currentWorkflowFile() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("currentWorkflowFile: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("currentWorkflowFile() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}